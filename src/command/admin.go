@@ -12,9 +12,25 @@ const UnsetAdminTrigger = "unsetadmin"
 // IsAdminTrigger is a trigger to use for an IsAdmin command.
 const IsAdminTrigger = "isadmin"
 
+// SetPresenceTrigger is a trigger to use for changing the bot's presence.
+const SetPresenceTrigger = "setpresence"
+
+// ReloadTrigger is a trigger to use for reloading the bot's configuration.
+const ReloadTrigger = "reload"
+
 // Repo is a URL to this project's repository. Useful for showing with help information.
 const Repo = "https://github.com/BKrajancic/boby"
 
+// BuiltinTriggers returns the triggers of commands that services add themselves, rather than
+// being configured. These include the commands of CustomCommandsAdmin, but not AdminCommands.
+func BuiltinTriggers() []string {
+	triggers := []string{HelpTrigger, SetupTrigger, SetPresenceTrigger, ReloadTrigger, PurgeCacheTrigger}
+	for _, cmd := range CustomCommandsAdmin(nil) {
+		triggers = append(triggers, cmd.Trigger)
+	}
+	return triggers
+}
+
 // AdminCommands returns an array of commands for handling admins.
 func AdminCommands() []Command {
 	return []Command{
@@ -0,0 +1,189 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+// defaultAggregateTimeout is how long an aggregate command waits for its sources, if its
+// timeout isn't configured.
+const defaultAggregateTimeout = 10 * time.Second
+
+// AggregateConfig can be made into a command that uses several sources at once (such as
+// several dictionaries), merging their results into one message with a field for each source.
+type AggregateConfig struct {
+	Trigger       string      // Word which triggers this command to activate.
+	Parameters    []Parameter // How to capture words, which are given to each source.
+	Title         string      // The title of the message. Every %s is replaced with the input. Can instead be a text/template template (see templateDelimiter).
+	Timeout       int         // Seconds to wait for each source, so a slow source doesn't delay the rest. If 0, 10 seconds are used.
+	Help          string      // Help message to display.
+	HelpInput     string      // Help message to display for input following command.
+	ReplyInThread bool        // See Command.ReplyInThread.
+}
+
+// Validate returns every problem found with this config.
+func (a AggregateConfig) Validate() error {
+	errs := validateTrigger(a.Trigger)
+	errs = append(errs, validateParameters(a.Parameters)...)
+	errs = append(errs, validateTemplate("Title", a.Title)...)
+	if a.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("the Timeout can't be negative"))
+	}
+	return errorsOrNil(errs)
+}
+
+// timeout returns how long to wait for each source.
+func (a AggregateConfig) timeout() time.Duration {
+	if a.Timeout == 0 {
+		return defaultAggregateTimeout
+	}
+	return time.Duration(a.Timeout) * time.Second
+}
+
+// title returns the title of the message for the input to a command.
+func (a AggregateConfig) title(msg []interface{}) string {
+	if isTextTemplate(a.Title) {
+		title, err := executeTemplate(a.Title, parameterData(a.Parameters, msg))
+		if err != nil {
+			return ""
+		}
+		return title
+	}
+
+	input := []string{}
+	for _, word := range msg {
+		input = append(input, fmt.Sprint(word))
+	}
+	return strings.ReplaceAll(a.Title, "%s", strings.Join(input, " "))
+}
+
+// Command makes a command from a config, which uses every one of sources at once.
+func (a AggregateConfig) Command(sources []Source) (Command, error) {
+	curry := func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+		a.onMessage(sender, user, msg, storage, sink, sources)
+	}
+
+	return Command{
+		Trigger:       a.Trigger,
+		Parameters:    a.Parameters,
+		Exec:          curry,
+		Help:          a.Help,
+		HelpInput:     a.HelpInput,
+		ReplyInThread: a.ReplyInThread,
+	}, nil
+}
+
+// sourceReply collects the messages a source sends, until the source finishes or times out.
+type sourceReply struct {
+	messages []service.Message
+	finished bool
+	mutex    sync.Mutex    // Lock when accessing messages or finished.
+	done     chan struct{} // Closed once the reply is finished.
+	once     sync.Once     // Used to finish the reply only once.
+}
+
+// sink adds message to the reply, unless the reply is finished.
+func (s *sourceReply) sink(_ service.Conversation, message service.Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.finished {
+		s.messages = append(s.messages, message)
+	}
+}
+
+// finish stops adding messages to the reply. Messages sent by the source afterwards are
+// dropped.
+func (s *sourceReply) finish() {
+	s.once.Do(func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.finished = true
+		close(s.done)
+	})
+}
+
+// wait waits until the reply is finished, and returns the messages that were added.
+func (s *sourceReply) wait() []service.Message {
+	<-s.done
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.messages
+}
+
+// fitField returns field, truncated to fit within the limits of a field. The value is truncated
+// so that it fits along with the URL, unless the URL is too long to fit at all.
+func fitField(field service.MessageField) service.MessageField {
+	field.Field = truncate(service.MaxFieldName, field.Field)
+	value := field.Value
+	field.Value = ""
+	valueLength := service.MaxFieldValue - field.ValueLength()
+	if valueLength <= 0 {
+		field.URL = ""
+		valueLength = service.MaxFieldValue
+	}
+
+	field.Value = truncate(valueLength, value)
+	return field
+}
+
+// sourceField returns a field summarising the results of a source in messages, or false if
+// there are none.
+func sourceField(name string, messages []service.Message) (service.MessageField, bool) {
+	for _, message := range messages {
+		if !isResult(message) {
+			continue
+		}
+
+		lines := []string{}
+		if message.Description != "" {
+			lines = append(lines, message.Description)
+		} else if message.Title != "" {
+			lines = append(lines, message.Title)
+		}
+
+		for _, field := range message.Fields {
+			lines = append(lines, fmt.Sprintf("%s: %s", field.Field, field.Value))
+		}
+
+		return fitField(service.MessageField{Field: name, Value: strings.Join(lines, "\n"), URL: message.URL}), true
+	}
+	return service.MessageField{}, false
+}
+
+// onMessage uses every source at once, and sends a message with a field for each source that
+// has a result within its timeout. If the fields don't fit in one message, several are sent.
+func (a AggregateConfig) onMessage(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), sources []Source) {
+	replies := make([]*sourceReply, len(sources))
+	for i, source := range sources {
+		reply := &sourceReply{done: make(chan struct{})}
+		replies[i] = reply
+		go func(source Source) {
+			// Each source has its own timer, after which its messages are dropped.
+			timer := time.AfterFunc(a.timeout(), reply.finish)
+			defer timer.Stop()
+			defer reply.finish()
+			source.Command.Exec(sender, user, msg, storage, reply.sink)
+		}(source)
+	}
+
+	fields := []service.MessageField{}
+	for i, reply := range replies {
+		if field, ok := sourceField(sources[i].Name, reply.wait()); ok {
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		sink(sender, service.Message{Title: "Error", Description: noSourceMessage, Failed: true})
+		return
+	}
+
+	for _, message := range splitFields(a.title(msg), fields) {
+		sink(sender, message)
+	}
+}
@@ -0,0 +1,158 @@
+package command
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAggregate(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := AggregateConfig{
+		Trigger:    "lookup",
+		Parameters: []Parameter{{Type: "string"}},
+		Title:      "Results for %s",
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Config should be valid, but got: %s", err)
+	}
+
+	aggregate, err := config.Command([]Source{
+		{Name: "First", Command: replyingCommand(service.Message{Title: "bahay", Description: "house", URL: "https://first"})},
+		{Name: "Failing", Command: replyingCommand(service.Message{Description: retrieveErrorMessage, Failed: true})},
+		{Name: "Second", Command: replyingCommand(service.Message{
+			Title:  "bahay",
+			Fields: []service.MessageField{{Field: "noun", Value: "home"}},
+		})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aggregate.Exec(testConversation, testSender, []interface{}{"bahay"}, nil, demoSender.SendMessage)
+	resultMessage, resultConversation := demoSender.PopMessage()
+
+	expected := service.Message{
+		Title: "Results for bahay",
+		Fields: []service.MessageField{
+			{Field: "First", Value: "house", URL: "https://first"},
+			{Field: "Second", Value: "bahay\nnoun: home"},
+		},
+	}
+
+	if diff := cmp.Diff(expected, resultMessage); diff != "" {
+		t.Errorf("Message was different: %s", diff)
+	}
+
+	if resultConversation != testConversation {
+		t.Errorf("Sender was different!")
+	}
+
+	if !demoSender.IsEmpty() {
+		t.Errorf("Too many messages!")
+	}
+}
+
+func TestAggregateTimeout(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	slow := Command{
+		Exec: func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+			<-blocked
+			sink(sender, service.Message{Description: "Too late"})
+		},
+	}
+
+	config := AggregateConfig{Trigger: "lookup", Title: "Results", Timeout: 1}
+	aggregate, _ := config.Command([]Source{
+		{Name: "Slow", Command: slow},
+		{Name: "Fast", Command: replyingCommand(service.Message{Description: "In time"})},
+	})
+
+	aggregate.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+
+	expected := []service.MessageField{{Field: "Fast", Value: "In time"}}
+	if diff := cmp.Diff(expected, resultMessage.Fields); diff != "" {
+		t.Errorf("Slow sources should be skipped: %s", diff)
+	}
+}
+
+func TestAggregateFieldLength(t *testing.T) {
+	long := strings.Repeat("a", service.MaxFieldValue+100)
+	field, ok := sourceField("Long", []service.Message{{Description: long}})
+	if !ok || utf8.RuneCountInString(field.Value) != service.MaxFieldValue {
+		t.Errorf("A field should be truncated to %d characters, was %d", service.MaxFieldValue, utf8.RuneCountInString(field.Value))
+	}
+}
+
+func TestAggregateLongSources(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	sources := []Source{}
+	for i := 0; i < service.MaxFields+5; i++ {
+		sources = append(sources, Source{
+			Name:    strings.Repeat("n", service.MaxFieldName+10),
+			Command: replyingCommand(service.Message{Description: strings.Repeat("a", 2000), URL: "https://example.com/long"}),
+		})
+	}
+
+	config := AggregateConfig{Trigger: "lookup", Title: "Results"}
+	aggregate, _ := config.Command(sources)
+	aggregate.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+
+	count := 0
+	for !demoSender.IsEmpty() {
+		resultMessage, _ := demoSender.PopMessage()
+		total := utf8.RuneCountInString(resultMessage.Title)
+		for _, field := range resultMessage.Fields {
+			if field.ValueLength() > service.MaxFieldValue || field.URL == "" {
+				t.Errorf("A field should fit with its URL, was %d characters", field.ValueLength())
+			}
+			total += utf8.RuneCountInString(field.Field) + field.ValueLength()
+			count++
+		}
+
+		if len(resultMessage.Fields) > service.MaxFields || total > service.MaxMessageTotal {
+			t.Errorf("A message had %d fields and %d characters", len(resultMessage.Fields), total)
+		}
+	}
+
+	if count != len(sources) {
+		t.Errorf("Every source should have a field, but there were %d", count)
+	}
+}
+
+func TestAggregateWithoutResult(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := AggregateConfig{Trigger: "lookup"}
+	aggregate, _ := config.Command([]Source{{Name: "Empty", Command: replyingCommand()}})
+
+	aggregate.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != noSourceMessage {
+		t.Errorf("Message was different: %v", resultMessage)
+	}
+
+	if err := (AggregateConfig{Trigger: "lookup", Timeout: -1}).Validate(); err == nil {
+		t.Errorf("A negative timeout should be invalid")
+	}
+}
@@ -0,0 +1,188 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+)
+
+// ErrUnavailable is returned instead of making a request to a host that keeps failing.
+var ErrUnavailable = errors.New("source is temporarily unavailable")
+
+// unavailableMessage is shown to users when a source is temporarily unavailable.
+const unavailableMessage = "This source is temporarily unavailable, please try again later."
+
+// hostState is how a Breaker tracks each host.
+type hostState struct {
+	failures  int       // Requests in a row that have failed.
+	openUntil time.Time // Until then, requests aren't made.
+	probing   bool      // If true, a request is being made to check if the host has recovered.
+}
+
+// A Breaker retries requests that fail due to temporary errors (using exponential backoff).
+// If requests to a host keep failing, the breaker "opens" and requests to the host
+// immediately fail with ErrUnavailable. After a cooldown, a single request is allowed to probe
+// whether the host has recovered. If it succeeds, requests are made as usual again.
+// It is safe to use from multiple goroutines.
+type Breaker struct {
+	Retries   int           // Times to retry a request that failed due to a temporary error.
+	Backoff   time.Duration // How long to wait before the first retry, which doubles for each retry.
+	Threshold int           // Requests in a row that fail before the breaker opens for a host.
+	Cooldown  time.Duration // How long the breaker stays open before probing the host.
+
+	hosts map[string]*hostState
+	mutex sync.Mutex          // Lock when accessing hosts.
+	sleep func(time.Duration) // Used to wait between retries.
+}
+
+// DefaultBreaker is the Breaker used by every command.
+var DefaultBreaker = NewBreaker()
+
+// NewBreaker returns a Breaker with sensible defaults.
+func NewBreaker() *Breaker {
+	return &Breaker{
+		Retries:   2,
+		Backoff:   500 * time.Millisecond,
+		Threshold: 5,
+		Cooldown:  time.Minute,
+		hosts:     make(map[string]*hostState),
+		sleep:     time.Sleep,
+	}
+}
+
+// isTemporary returns true if err is likely to be fixed by trying again, such as a timeout,
+// a failed connection or a server error. Other errors, such as an invalid URL or an unknown
+// host, are permanent.
+func isTemporary(err error) bool {
+	var statusErr StatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// isIdempotent returns true if making request more than once has the same effect as making it
+// once, so that it can be retried.
+func isIdempotent(request utils.Request) bool {
+	switch strings.ToUpper(request.Method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestHost returns the host a request is made to.
+func requestHost(request utils.Request) string {
+	if parsed, err := url.Parse(request.URL); err == nil {
+		return parsed.Host
+	}
+	return ""
+}
+
+// allow returns true if a request can be made to host.
+func (b *Breaker) allow(host string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state, ok := b.hosts[host]
+	if !ok || state.failures < b.Threshold {
+		return true
+	}
+
+	if state.probing || time.Now().Before(state.openUntil) {
+		return false
+	}
+
+	state.probing = true
+	return true
+}
+
+// record updates the state of host after a request was made, which failed if err isn't nil.
+func (b *Breaker) record(host string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err == nil || !isTemporary(err) {
+		delete(b.hosts, host)
+		return
+	}
+
+	state, ok := b.hosts[host]
+	if !ok {
+		state = &hostState{}
+		b.hosts[host] = state
+	}
+
+	state.failures++
+	state.probing = false
+	if state.failures >= b.Threshold {
+		state.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// do makes a request using try, retrying if it fails with a temporary error and the request
+// is idempotent.
+func (b *Breaker) do(request utils.Request, try func() error) error {
+	host := requestHost(request)
+	if !b.allow(host) {
+		return fmt.Errorf("%s: %w", host, ErrUnavailable)
+	}
+
+	retries := b.Retries
+	if !isIdempotent(request) {
+		retries = 0
+	}
+
+	err := try()
+	backoff := b.Backoff
+	for retry := 0; retry < retries && err != nil && isTemporary(err); retry++ {
+		b.sleep(backoff)
+		backoff *= 2
+		err = try()
+	}
+
+	b.record(host, err)
+	return err
+}
+
+// HTMLRequester returns an HTMLRequester that uses requester, retrying and breaking as needed.
+func (b *Breaker) HTMLRequester(requester HTMLRequester) HTMLRequester {
+	return func(request utils.Request) (redirect string, out io.ReadCloser, err error) {
+		err = b.do(request, func() (err error) {
+			redirect, out, err = requester(request)
+			return err
+		})
+		return redirect, out, err
+	}
+}
+
+// JSONRequester returns a JSONRequester that uses requester, retrying and breaking as needed.
+func (b *Breaker) JSONRequester(requester JSONRequester) JSONRequester {
+	return func(request utils.Request) (out io.ReadCloser, err error) {
+		err = b.do(request, func() (err error) {
+			out, err = requester(request)
+			return err
+		})
+		return out, err
+	}
+}
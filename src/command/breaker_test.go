@@ -0,0 +1,168 @@
+package command
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+)
+
+// testBreaker returns a Breaker that records how long it waits, rather than waiting.
+func testBreaker(waits *[]time.Duration) *Breaker {
+	breaker := NewBreaker()
+	breaker.sleep = func(duration time.Duration) {
+		*waits = append(*waits, duration)
+	}
+	return breaker
+}
+
+// failingRequester returns an HTMLRequester that fails with err the first failures times.
+func failingRequester(err error, failures int, calls *int) HTMLRequester {
+	return func(request utils.Request) (string, io.ReadCloser, error) {
+		*calls++
+		if *calls <= failures {
+			return "", nil, err
+		}
+		return request.URL, ioutil.NopCloser(strings.NewReader("ok")), nil
+	}
+}
+
+var errServer = StatusError{StatusCode: 503, Status: "503 Service Unavailable"}
+
+func TestBreakerRetries(t *testing.T) {
+	waits := []time.Duration{}
+	calls := 0
+	requester := testBreaker(&waits).HTMLRequester(failingRequester(errServer, 2, &calls))
+
+	if _, _, err := requester(utils.Request{URL: "https://example.com/a"}); err != nil {
+		t.Errorf("A request should succeed after retrying: %s", err)
+	}
+
+	if calls != 3 || len(waits) != 2 || waits[1] != 2*waits[0] {
+		t.Errorf("Expected 2 retries with exponential backoff, got %d calls and waits %v", calls, waits)
+	}
+}
+
+func TestBreakerDoesntRetryPermanentErrors(t *testing.T) {
+	waits := []time.Duration{}
+	calls := 0
+	requester := testBreaker(&waits).HTMLRequester(failingRequester(errors.New("invalid"), 1, &calls))
+
+	if _, _, err := requester(utils.Request{URL: "https://example.com/a"}); err == nil || calls != 1 {
+		t.Errorf("A permanent error shouldn't be retried")
+	}
+}
+
+func TestBreakerTemporaryErrors(t *testing.T) {
+	tests := map[error]bool{
+		errServer: true,
+		&url.Error{Op: "Get", URL: "https://example.com/", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}:           true,
+		&url.Error{Op: "Get", URL: "https://example.com/", Err: &net.DNSError{IsTimeout: true}}:                                 true,
+		&url.Error{Op: "Get", URL: "https://example.com/", Err: &net.OpError{Op: "dial", Err: &net.DNSError{IsNotFound: true}}}: false,
+		&url.Error{Op: "Get", URL: "ftp://example.com/", Err: errors.New("unsupported protocol scheme")}:                        false,
+	}
+
+	for err, expected := range tests {
+		if isTemporary(err) != expected {
+			t.Errorf("isTemporary(%v) should be %v", err, expected)
+		}
+	}
+}
+
+func TestBreakerDoesntRetryPost(t *testing.T) {
+	waits := []time.Duration{}
+	calls := 0
+	requester := testBreaker(&waits).HTMLRequester(failingRequester(errServer, 1, &calls))
+
+	if _, _, err := requester(utils.Request{Method: "POST", URL: "https://example.com/a"}); err == nil || calls != 1 {
+		t.Errorf("A request that isn't idempotent shouldn't be retried")
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	waits := []time.Duration{}
+	breaker := testBreaker(&waits)
+	breaker.Threshold = 2
+
+	calls := 0
+	requester := breaker.HTMLRequester(failingRequester(errServer, 100, &calls))
+	for i := 0; i < breaker.Threshold; i++ {
+		requester(utils.Request{URL: "https://example.com/a"})
+	}
+
+	callsBefore := calls
+	_, _, err := requester(utils.Request{URL: "https://example.com/b"})
+	if !errors.Is(err, ErrUnavailable) || calls != callsBefore {
+		t.Errorf("An open breaker shouldn't make requests: %v", err)
+	}
+
+	otherCalls := 0
+	other := breaker.HTMLRequester(failingRequester(errServer, 0, &otherCalls))
+	if _, _, err := other(utils.Request{URL: "https://example.org/"}); err != nil {
+		t.Errorf("Other hosts should be unaffected: %s", err)
+	}
+}
+
+func TestBreakerRecovers(t *testing.T) {
+	waits := []time.Duration{}
+	breaker := testBreaker(&waits)
+	breaker.Threshold = 1
+	breaker.Retries = 0
+	breaker.Cooldown = 0
+
+	calls := 0
+	requester := breaker.HTMLRequester(failingRequester(errServer, 2, &calls))
+	request := utils.Request{URL: "https://example.com/a"}
+
+	requester(request)
+	if _, _, err := requester(request); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("After the cooldown, a probe should be made: %v", err)
+	}
+
+	if _, _, err := requester(request); err != nil {
+		t.Errorf("A successful probe should close the breaker: %v", err)
+	}
+
+	if _, ok := breaker.hosts["example.com"]; ok {
+		t.Errorf("A recovered host shouldn't be tracked")
+	}
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	breaker := NewBreaker()
+	breaker.Threshold = 1
+	breaker.Cooldown = 0
+	breaker.record("example.com", errServer)
+
+	if !breaker.allow("example.com") {
+		t.Errorf("A probe should be allowed after the cooldown")
+	}
+
+	if breaker.allow("example.com") {
+		t.Errorf("Only a single probe should be allowed at a time")
+	}
+}
+
+func TestUnavailableMessage(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := GoQueryScraperConfig{URL: "https://unavailable.example.com/"}
+	command, _ := config.CommandWithHTMLRequester(func(request utils.Request) (string, io.ReadCloser, error) {
+		return "", nil, ErrUnavailable
+	})
+
+	command.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != unavailableMessage {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
@@ -0,0 +1,242 @@
+package command
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+)
+
+// defaultCacheSize is the most responses cached for a command, if its size isn't configured.
+const defaultCacheSize = 100
+
+// CacheConfig describes how a command caches the responses to its requests.
+type CacheConfig struct {
+	TTL     int  // Seconds that a response is cached for. If 0, responses aren't cached.
+	Size    int  // The most responses to cache for this command. If 0, 100 responses are cached.
+	Persist bool // If true, cached responses are kept when the bot restarts.
+}
+
+// validate returns a problem if TTL or Size is negative.
+func (c CacheConfig) validate() Errors {
+	errs := Errors{}
+	if c.TTL < 0 {
+		errs = append(errs, fmt.Errorf("the Cache.TTL can't be negative"))
+	}
+
+	if c.Size < 0 {
+		errs = append(errs, fmt.Errorf("the Cache.Size can't be negative"))
+	}
+	return errs
+}
+
+// A CachedResponse is a response to a request, which is reused until it expires.
+type CachedResponse struct {
+	URL     string    // Where the response came from, after any redirects.
+	Body    []byte    // The contents of the response.
+	Expires time.Time // When the response should no longer be used.
+	Persist bool      // If true, the response is saved.
+}
+
+// A ResponseCache stores responses for each command, so a request doesn't need to be made again.
+// It is safe to use from multiple goroutines.
+type ResponseCache struct {
+	responses map[string]map[string]CachedResponse // Responses for each trigger, by cacheKey.
+	mutex     sync.Mutex                           // Lock when accessing responses.
+}
+
+// DefaultCache is the ResponseCache used by every command.
+var DefaultCache = NewResponseCache()
+
+// NewResponseCache returns an empty ResponseCache.
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{responses: make(map[string]map[string]CachedResponse)}
+}
+
+// cacheKey returns the key of a request, which is the same for any identical request.
+func cacheKey(request utils.Request) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n%s:%s\n%s\n", request.Method, request.URL, request.Body, request.Username, request.Password, request.Encoding)
+
+	keys := make([]string, 0, len(request.Header))
+	for key := range request.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s: %q\n", key, request.Header[key])
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// get returns the response to a request made by the command with trigger, if it hasn't expired.
+func (c *ResponseCache) get(trigger string, request utils.Request) (CachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	response, ok := c.responses[trigger][cacheKey(request)]
+	if !ok || time.Now().After(response.Expires) {
+		return CachedResponse{}, false
+	}
+	return response, true
+}
+
+// set stores the response to a request made by the command with trigger.
+// If the command has more than size responses, those closest to expiring are removed.
+func (c *ResponseCache) set(trigger string, request utils.Request, response CachedResponse, size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	responses, ok := c.responses[trigger]
+	if !ok {
+		responses = make(map[string]CachedResponse)
+		c.responses[trigger] = responses
+	}
+	responses[cacheKey(request)] = response
+
+	now := time.Now()
+	for key, response := range responses {
+		if now.After(response.Expires) {
+			delete(responses, key)
+		}
+	}
+
+	for len(responses) > size {
+		oldest := ""
+		for key, response := range responses {
+			if oldest == "" || response.Expires.Before(responses[oldest].Expires) {
+				oldest = key
+			}
+		}
+		delete(responses, oldest)
+	}
+}
+
+// Purge removes every response of the command with trigger, or of every command if trigger is
+// empty. The number of responses removed is returned.
+func (c *ResponseCache) Purge(trigger string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	purged := 0
+	for cachedTrigger, responses := range c.responses {
+		if trigger == "" || cachedTrigger == trigger {
+			purged += len(responses)
+			delete(c.responses, cachedTrigger)
+		}
+	}
+	return purged
+}
+
+// Save writes every response that should persist and hasn't expired to writer.
+func (c *ResponseCache) Save(writer io.Writer) error {
+	c.mutex.Lock()
+	saved := make(map[string]map[string]CachedResponse)
+	now := time.Now()
+	for trigger, responses := range c.responses {
+		for key, response := range responses {
+			if response.Persist && now.Before(response.Expires) {
+				if _, ok := saved[trigger]; !ok {
+					saved[trigger] = make(map[string]CachedResponse)
+				}
+				saved[trigger][key] = response
+			}
+		}
+	}
+	c.mutex.Unlock()
+
+	return gob.NewEncoder(writer).Encode(saved)
+}
+
+// Load reads responses written by Save from reader, adding them to the cache.
+func (c *ResponseCache) Load(reader io.Reader) error {
+	loaded := make(map[string]map[string]CachedResponse)
+	if err := gob.NewDecoder(reader).Decode(&loaded); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for trigger, responses := range loaded {
+		if _, ok := c.responses[trigger]; !ok {
+			c.responses[trigger] = make(map[string]CachedResponse)
+		}
+
+		for key, response := range responses {
+			c.responses[trigger][key] = response
+		}
+	}
+	return nil
+}
+
+// response returns a response that expires according to config.
+func (c CacheConfig) response(url string, body []byte) CachedResponse {
+	return CachedResponse{
+		URL:     url,
+		Body:    body,
+		Expires: time.Now().Add(time.Duration(c.TTL) * time.Second),
+		Persist: c.Persist,
+	}
+}
+
+// size returns the most responses to cache.
+func (c CacheConfig) size() int {
+	if c.Size == 0 {
+		return defaultCacheSize
+	}
+	return c.Size
+}
+
+// HTMLRequester returns an HTMLRequester that uses cached responses for requests made by the
+// command with trigger. If config has no TTL, requester is returned.
+func (c *ResponseCache) HTMLRequester(trigger string, config CacheConfig, requester HTMLRequester) HTMLRequester {
+	if config.TTL <= 0 {
+		return requester
+	}
+
+	return func(request utils.Request) (string, io.ReadCloser, error) {
+		if response, ok := c.get(trigger, request); ok {
+			return response.URL, ioutil.NopCloser(bytes.NewReader(response.Body)), nil
+		}
+
+		url, reader, err := requester(request)
+		if err != nil {
+			return url, reader, err
+		}
+
+		defer reader.Close()
+		body, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return url, nil, err
+		}
+
+		c.set(trigger, request, config.response(url, body), config.size())
+		return url, ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// JSONRequester returns a JSONRequester that uses cached responses for requests made by the
+// command with trigger. If config has no TTL, requester is returned.
+func (c *ResponseCache) JSONRequester(trigger string, config CacheConfig, requester JSONRequester) JSONRequester {
+	if config.TTL <= 0 {
+		return requester
+	}
+
+	htmlRequester := c.HTMLRequester(trigger, config, func(request utils.Request) (string, io.ReadCloser, error) {
+		reader, err := requester(request)
+		return request.URL, reader, err
+	})
+
+	return func(request utils.Request) (io.ReadCloser, error) {
+		_, reader, err := htmlRequester(request)
+		return reader, err
+	}
+}
@@ -0,0 +1,184 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+)
+
+// countingRequester returns an HTMLRequester that returns content, counting how often it's used.
+func countingRequester(content string, count *int) HTMLRequester {
+	return func(request utils.Request) (string, io.ReadCloser, error) {
+		*count++
+		return request.URL, ioutil.NopCloser(strings.NewReader(content)), nil
+	}
+}
+
+func readAll(t *testing.T, reader io.ReadCloser) string {
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestCacheKey(t *testing.T) {
+	request := utils.Request{Method: "GET", URL: "https://example.com", Header: http.Header{}}
+	request.Header.Set("X-Api-Key", "a")
+
+	same := utils.Request{Method: "GET", URL: "https://example.com", Header: http.Header{}}
+	same.Header.Set("X-Api-Key", "a")
+	if cacheKey(request) != cacheKey(same) {
+		t.Errorf("Identical requests should have the same key")
+	}
+
+	same.Header.Set("X-Api-Key", "b")
+	if cacheKey(request) == cacheKey(same) {
+		t.Errorf("Requests with different headers should have different keys")
+	}
+
+	if cacheKey(request) == cacheKey(utils.Request{Method: "POST", URL: "https://example.com"}) {
+		t.Errorf("Requests with different methods should have different keys")
+	}
+}
+
+func TestCachedRequester(t *testing.T) {
+	cache := NewResponseCache()
+	count := 0
+	requester := cache.HTMLRequester("test", CacheConfig{TTL: 60}, countingRequester("content", &count))
+
+	for i := 0; i < 3; i++ {
+		url, reader, err := requester(utils.Request{URL: "a"})
+		if err != nil || url != "a" || readAll(t, reader) != "content" {
+			t.Errorf("A cached response should be the same as the original response")
+		}
+	}
+
+	requester(utils.Request{URL: "b"})
+	if count != 2 {
+		t.Errorf("Expected 2 requests, got %d", count)
+	}
+
+	if cache.Purge("other") != 0 || cache.Purge("test") != 2 {
+		t.Errorf("Purging should remove responses of the command only")
+	}
+
+	requester(utils.Request{URL: "a"})
+	if count != 3 {
+		t.Errorf("A purged response should be requested again")
+	}
+}
+
+func TestCacheWithoutTTL(t *testing.T) {
+	cache := NewResponseCache()
+	count := 0
+	requester := cache.HTMLRequester("test", CacheConfig{}, countingRequester("content", &count))
+
+	requester(utils.Request{URL: "a"})
+	requester(utils.Request{URL: "a"})
+	if count != 2 {
+		t.Errorf("Without a TTL, responses shouldn't be cached")
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	cache := NewResponseCache()
+	cache.set("test", utils.Request{URL: "a"}, CachedResponse{Expires: time.Now().Add(-time.Second)}, 10)
+	if _, ok := cache.get("test", utils.Request{URL: "a"}); ok {
+		t.Errorf("An expired response shouldn't be used")
+	}
+}
+
+func TestCacheSize(t *testing.T) {
+	cache := NewResponseCache()
+	config := CacheConfig{TTL: 60, Size: 2}
+	for i, url := range []string{"a", "b", "c"} {
+		response := config.response(url, nil)
+		response.Expires = response.Expires.Add(time.Duration(i) * time.Second)
+		cache.set("test", utils.Request{URL: url}, response, config.size())
+	}
+
+	if _, ok := cache.get("test", utils.Request{URL: "a"}); ok {
+		t.Errorf("The oldest response should be removed")
+	}
+
+	if _, ok := cache.get("test", utils.Request{URL: "c"}); !ok {
+		t.Errorf("The newest response should be kept")
+	}
+}
+
+func TestCachePersistence(t *testing.T) {
+	cache := NewResponseCache()
+	cache.set("kept", utils.Request{URL: "a"}, CacheConfig{TTL: 60, Persist: true}.response("a", []byte("content")), 10)
+	cache.set("lost", utils.Request{URL: "a"}, CacheConfig{TTL: 60}.response("a", []byte("content")), 10)
+
+	var buffer bytes.Buffer
+	if err := cache.Save(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewResponseCache()
+	if err := loaded.Load(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if response, ok := loaded.get("kept", utils.Request{URL: "a"}); !ok || string(response.Body) != "content" {
+		t.Errorf("A persistent response should be loaded")
+	}
+
+	if _, ok := loaded.get("lost", utils.Request{URL: "a"}); ok {
+		t.Errorf("Only persistent responses should be saved")
+	}
+}
+
+func TestJSONGetterCache(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+	defer DefaultCache.Purge("cachedjson")
+
+	config := JSONGetterConfig{
+		Trigger: "cachedjson",
+		URL:     "https://example.com/",
+		Cache:   CacheConfig{TTL: 60},
+		Message: JSONCapture{Body: FieldCapture{Template: "%s", Selectors: []string{"result"}}},
+	}
+
+	count := 0
+	command, _ := config.CommandWithRequester(func(request utils.Request) (io.ReadCloser, error) {
+		count++
+		return ioutil.NopCloser(strings.NewReader(`{"result": "ok"}`)), nil
+	})
+
+	for i := 0; i < 2; i++ {
+		command.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+		if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "ok" {
+			t.Errorf("Unexpected message: %v", resultMessage)
+		}
+	}
+
+	if count != 1 {
+		t.Errorf("The second request should be cached")
+	}
+}
+
+func TestPurgeCache(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+	DefaultCache.set("purged", utils.Request{URL: "a"}, CacheConfig{TTL: 60}.response("a", nil), 10)
+
+	conversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	PurgeCache(conversation, testSender, []interface{}{"all"}, nil, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "1 cached responses have been purged." {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
@@ -8,12 +8,13 @@ import (
 
 // A Command is how a User interacts with a bot.
 type Command struct {
-	Trigger    string      // Messages starting with Trigger are processed by this Command.
-	Parameters []Parameter // What text to capture following a trigger.
-	Help       string      // What this command does.
-	HelpInput  string      // Arguments following the trigger.
-	Exec       func(service.Conversation, service.User, []interface{}, *storage.Storage, func(service.Conversation, service.Message))
-	observers  []service.Sender
+	Trigger       string      // Messages starting with Trigger are processed by this Command.
+	Parameters    []Parameter // What text to capture following a trigger.
+	Help          string      // What this command does.
+	HelpInput     string      // Arguments following the trigger.
+	ReplyInThread bool        // If true, replies are sent in a new thread made from the triggering message (where supported), which is useful for long results.
+	Exec          func(service.Conversation, service.User, []interface{}, *storage.Storage, func(service.Conversation, service.Message))
+	observers     []service.Sender
 }
 
 // A Parameter captures input to a command.
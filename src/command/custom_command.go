@@ -0,0 +1,313 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+// CustomCommandKeyPrefix starts the key used in storage for each custom command of a guild.
+// The rest of the key is the command's trigger, and its value is the command's reply.
+const CustomCommandKeyPrefix = "command:"
+
+// AddCommandTrigger is a trigger to use for an AddCustomCommand command.
+const AddCommandTrigger = "addcommand"
+
+// EditCommandTrigger is a trigger to use for an EditCustomCommand command.
+const EditCommandTrigger = "editcommand"
+
+// DeleteCommandTrigger is a trigger to use for a DeleteCustomCommand command.
+const DeleteCommandTrigger = "deletecommand"
+
+// ListCommandsTrigger is a trigger to use for a ListCustomCommands command.
+const ListCommandsTrigger = "listcommands"
+
+// maxCustomCommands is the most custom commands that a guild can have.
+const maxCustomCommands = 100
+
+// customHelpLength is the most characters of a reply that are shown as a custom command's help.
+const customHelpLength = 80
+
+// CustomCommands returns a Command for each custom command of guild, ordered by trigger.
+// As custom commands have no parameters, services should give them each word following the
+// trigger as input.
+func CustomCommands(guild service.Guild, storage *storage.Storage) []Command {
+	triggers, replies := customReplies(guild, storage)
+	commands := make([]Command, 0, len(triggers))
+	for _, trigger := range triggers {
+		commands = append(commands, Command{
+			Trigger:   trigger,
+			Help:      "Replies with: " + truncate(customHelpLength, replies[trigger]),
+			HelpInput: "[input]",
+			Exec:      customExec(replies[trigger]),
+		})
+	}
+	return commands
+}
+
+// CustomCommandsAdmin returns commands for creating, changing, deleting and listing the custom
+// commands of a guild. Custom commands can't use the trigger of any command that commands
+// returns, which should be every other command of a service.
+func CustomCommandsAdmin(commands func() []Command) []Command {
+	return []Command{
+		{
+			Trigger: AddCommandTrigger,
+			Parameters: []Parameter{
+				{
+					Name:        "command",
+					Description: "Trigger of the new command.",
+					Type:        "string",
+				},
+				{
+					Name:        "reply",
+					Description: "What the command replies with. Each %s is replaced with input.",
+					Type:        "string",
+				},
+			},
+			Exec:      AddCustomCommand(commands),
+			Help:      "Create a command for this server that replies with text. Each %s in the reply is replaced with a word of input, and the last with the rest of the input. It can't be used as a slash command.",
+			HelpInput: "[command] [reply]",
+		},
+
+		{
+			Trigger: EditCommandTrigger,
+			Parameters: []Parameter{
+				{
+					Name:        "command",
+					Description: "Trigger of the command to change.",
+					Type:        "string",
+				},
+				{
+					Name:        "reply",
+					Description: "What the command replies with. Each %s is replaced with input.",
+					Type:        "string",
+				},
+			},
+			Exec:      EditCustomCommand(commands),
+			Help:      "Change the reply of a command that was created for this server.",
+			HelpInput: "[command] [reply]",
+		},
+
+		{
+			Trigger: DeleteCommandTrigger,
+			Parameters: []Parameter{
+				{
+					Name:        "command",
+					Description: "Trigger of the command to delete.",
+					Type:        "string",
+				},
+			},
+			Exec:      DeleteCustomCommand,
+			Help:      "Delete a command that was created for this server.",
+			HelpInput: "[command]",
+		},
+
+		{
+			Trigger: ListCommandsTrigger,
+			Exec:    ListCustomCommands,
+			Help:    "List the commands that were created for this server, with their replies.",
+		},
+	}
+}
+
+// customExec returns the Exec of a custom command, which sends reply filled out with its input.
+func customExec(reply string) func(service.Conversation, service.User, []interface{}, *storage.Storage, func(service.Conversation, service.Message)) {
+	return func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+		text, err := customReply(reply, user, msg)
+		if err != nil {
+			text = "An error occurred when filling out the reply of this command."
+		}
+		sink(sender, service.Message{Description: text})
+	}
+}
+
+// customReplies returns the reply of each custom command of guild by trigger, and the triggers
+// in order.
+func customReplies(guild service.Guild, storage *storage.Storage) ([]string, map[string]string) {
+	triggers := []string{}
+	replies := make(map[string]string)
+	for key, value := range (*storage).GetGuildValues(guild) {
+		if reply, ok := value.(string); ok && strings.HasPrefix(key, CustomCommandKeyPrefix) {
+			trigger := strings.TrimPrefix(key, CustomCommandKeyPrefix)
+			triggers = append(triggers, trigger)
+			replies[trigger] = reply
+		}
+	}
+	sort.Strings(triggers)
+	return triggers, replies
+}
+
+// customReply fills out the reply of a custom command using the words of its input.
+// Each "%s" is replaced with a word, except the last which is replaced with every remaining
+// word. Replies can instead be text/template templates, which can use .Input (every word),
+// .Parameters (each word) and .User.
+func customReply(reply string, user service.User, msg []interface{}) (string, error) {
+	words := make([]string, len(msg))
+	for i, word := range msg {
+		words[i] = fmt.Sprintf("%v", word)
+	}
+
+	if isTextTemplate(reply) {
+		data := parameterData(nil, msg)
+		data["Input"] = strings.Join(words, " ")
+		data["User"] = user.Name
+		return executeTemplate(reply, data)
+	}
+
+	parts := strings.Split(reply, "%s")
+	var result strings.Builder
+	result.WriteString(parts[0])
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && i < len(words) {
+			result.WriteString(strings.Join(words[i:], " "))
+		} else if i < len(words) {
+			result.WriteString(words[i])
+		}
+		result.WriteString(part)
+	}
+	return result.String(), nil
+}
+
+// validateCustomCommand returns a problem if trigger can't be used for a custom command, or
+// reply isn't a valid template. Triggers of builtin commands, and of commands, can't be used.
+func validateCustomCommand(trigger string, reply string, commands func() []Command) Errors {
+	errs := validateTrigger(trigger)
+	reserved := BuiltinTriggers()
+	for _, cmd := range AdminCommands() {
+		reserved = append(reserved, cmd.Trigger)
+	}
+
+	if commands != nil {
+		for _, cmd := range commands() {
+			reserved = append(reserved, cmd.Trigger)
+		}
+	}
+
+	for _, used := range reserved {
+		if strings.EqualFold(trigger, used) {
+			errs = append(errs, fmt.Errorf("\"%s\" is used by another command", trigger))
+			break
+		}
+	}
+
+	if strings.TrimSpace(reply) == "" {
+		errs = append(errs, fmt.Errorf("the reply can't be empty"))
+	}
+	return append(errs, validateTemplate("the reply", reply)...)
+}
+
+// setCustomCommand creates or edits the custom command with the trigger and reply of msg,
+// replying with any problem. Only admins are able to use this.
+func setCustomCommand(sender service.Conversation, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), commands func() []Command, create bool) {
+	if !sender.Admin {
+		return
+	}
+
+	trigger, reply := msg[0].(string), msg[1].(string)
+	key := CustomCommandKeyPrefix + trigger
+	_, exists := (*storage).GetGuildValue(sender.Guild(), key)
+
+	var problem string
+	if errs := validateCustomCommand(trigger, reply, commands); len(errs) > 0 {
+		problem = errs.Error()
+	} else if create && exists {
+		problem = fmt.Sprintf("'%s' already exists, use %s to change it.", trigger, EditCommandTrigger)
+	} else if !create && !exists {
+		problem = fmt.Sprintf("'%s' doesn't exist, use %s to create it.", trigger, AddCommandTrigger)
+	} else if triggers, _ := customReplies(sender.Guild(), storage); create && len(triggers) >= maxCustomCommands {
+		problem = fmt.Sprintf("This server already has %d custom commands.", maxCustomCommands)
+	}
+
+	if problem != "" {
+		sink(sender, service.Message{Title: "Error", Description: problem, Failed: true})
+		return
+	}
+
+	(*storage).SetGuildValue(sender.Guild(), key, reply)
+	if create {
+		sink(sender, service.Message{Description: fmt.Sprintf("'%s' has been created.", trigger)})
+	} else {
+		sink(sender, service.Message{Description: fmt.Sprintf("'%s' has been changed.", trigger)})
+	}
+}
+
+// AddCustomCommand returns an Exec that creates a custom command for a guild, that replies
+// with text. The triggers of commands can't be used. Only admins are able to use this.
+func AddCustomCommand(commands func() []Command) func(service.Conversation, service.User, []interface{}, *storage.Storage, func(service.Conversation, service.Message)) {
+	return func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+		setCustomCommand(sender, msg, storage, sink, commands, true)
+	}
+}
+
+// EditCustomCommand returns an Exec that changes the reply of a custom command of a guild.
+// The triggers of commands can't be used. Only admins are able to use this.
+func EditCustomCommand(commands func() []Command) func(service.Conversation, service.User, []interface{}, *storage.Storage, func(service.Conversation, service.Message)) {
+	return func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+		setCustomCommand(sender, msg, storage, sink, commands, false)
+	}
+}
+
+// DeleteCustomCommand deletes a custom command of a guild. Only admins are able to use this.
+func DeleteCustomCommand(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+	if !sender.Admin {
+		return
+	}
+
+	trigger := msg[0].(string)
+	key := CustomCommandKeyPrefix + trigger
+	if _, exists := (*storage).GetGuildValue(sender.Guild(), key); !exists {
+		sink(sender, service.Message{Title: "Error", Description: fmt.Sprintf("'%s' doesn't exist.", trigger), Failed: true})
+		return
+	}
+
+	(*storage).UnsetGuildValue(sender.Guild(), key)
+	sink(sender, service.Message{Description: fmt.Sprintf("'%s' has been deleted.", trigger)})
+}
+
+// ListCustomCommands lists the custom commands of a guild, with their replies. If there are
+// too many to fit in a message, several messages are sent.
+func ListCustomCommands(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+	triggers, replies := customReplies(sender.Guild(), storage)
+	fields := []service.MessageField{}
+	for _, trigger := range triggers {
+		fields = append(fields, service.MessageField{
+			Field: truncate(service.MaxFieldName, trigger),
+			Value: truncate(service.MaxFieldValue, replies[trigger]),
+		})
+	}
+
+	if len(fields) == 0 {
+		sink(sender, service.Message{
+			Description: fmt.Sprintf("This server has no custom commands, admins can create them using %s.", AddCommandTrigger),
+		})
+		return
+	}
+
+	for _, message := range splitFields("Custom commands", fields) {
+		sink(sender, message)
+	}
+}
+
+// splitFields returns messages with title, that together have every field of fields. Each
+// message has as many fields as fit within the limits of a message.
+func splitFields(title string, fields []service.MessageField) []service.Message {
+	messages := []service.Message{}
+	current := service.Message{Title: title}
+	size := utf8.RuneCountInString(title)
+	for _, field := range fields {
+		fieldSize := utf8.RuneCountInString(field.Field) + field.ValueLength()
+		if len(current.Fields) > 0 && (len(current.Fields) == service.MaxFields || size+fieldSize > service.MaxMessageTotal) {
+			messages = append(messages, current)
+			current = service.Message{Title: title}
+			size = utf8.RuneCountInString(title)
+		}
+
+		current.Fields = append(current.Fields, field)
+		size += fieldSize
+	}
+	return append(messages, current)
+}
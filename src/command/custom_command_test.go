@@ -0,0 +1,218 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+// customCommandsTest returns storage, a sender and a conversation of a guild, for testing
+// custom commands.
+func customCommandsTest(admin bool) (*storage.Storage, *demoservice.DemoSender, service.Conversation) {
+	tempStorage := storage.GetTempStorage()
+	var _storage storage.Storage = &tempStorage
+
+	demoSender := demoservice.DemoSender{ServiceID: demoservice.ServiceID}
+	conversation := service.Conversation{
+		ServiceID:      demoSender.ID(),
+		ConversationID: "0",
+		GuildID:        "0",
+		Admin:          admin,
+	}
+	return &_storage, &demoSender, conversation
+}
+
+func TestAddCustomCommand(t *testing.T) {
+	storage, demoSender, conversation := customCommandsTest(true)
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	AddCustomCommand(nil)(conversation, testSender, []interface{}{"rules", "Please read the rules, %s!"}, storage, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "'rules' has been created." {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+
+	commands := CustomCommands(conversation.Guild(), storage)
+	if len(commands) != 1 || commands[0].Trigger != "rules" {
+		t.Fatalf("Unexpected commands: %v", commands)
+	}
+
+	commands[0].Exec(conversation, testSender, []interface{}{"new", "members"}, storage, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "Please read the rules, new members!" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+
+	otherGuild := service.Guild{ServiceID: conversation.ServiceID, GuildID: "1"}
+	if len(CustomCommands(otherGuild, storage)) != 0 {
+		t.Errorf("Custom commands should only belong to one guild")
+	}
+
+	AddCustomCommand(nil)(conversation, testSender, []interface{}{"rules", "Different"}, storage, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Title != "Error" {
+		t.Errorf("A command that exists shouldn't be created again: %v", resultMessage)
+	}
+}
+
+func TestEditDeleteCustomCommand(t *testing.T) {
+	storage, demoSender, conversation := customCommandsTest(true)
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	EditCustomCommand(nil)(conversation, testSender, []interface{}{"tips", "Study!"}, storage, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Title != "Error" {
+		t.Errorf("A command that doesn't exist shouldn't be changed: %v", resultMessage)
+	}
+
+	AddCustomCommand(nil)(conversation, testSender, []interface{}{"tips", "Study!"}, storage, demoSender.SendMessage)
+	EditCustomCommand(nil)(conversation, testSender, []interface{}{"tips", "Study every day!"}, storage, demoSender.SendMessage)
+	demoSender.PopMessage()
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "'tips' has been changed." {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+
+	commands := CustomCommands(conversation.Guild(), storage)
+	commands[0].Exec(conversation, testSender, []interface{}{}, storage, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "Study every day!" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+
+	DeleteCustomCommand(conversation, testSender, []interface{}{"tips"}, storage, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "'tips' has been deleted." {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+
+	if len(CustomCommands(conversation.Guild(), storage)) != 0 {
+		t.Errorf("A deleted command shouldn't be used")
+	}
+
+	DeleteCustomCommand(conversation, testSender, []interface{}{"tips"}, storage, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Title != "Error" {
+		t.Errorf("A command that doesn't exist shouldn't be deleted: %v", resultMessage)
+	}
+}
+
+func TestCustomCommandNotAdmin(t *testing.T) {
+	storage, demoSender, conversation := customCommandsTest(false)
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	AddCustomCommand(nil)(conversation, testSender, []interface{}{"rules", "Be nice."}, storage, demoSender.SendMessage)
+	if !demoSender.IsEmpty() || len(CustomCommands(conversation.Guild(), storage)) != 0 {
+		t.Errorf("Only admins should be able to create commands")
+	}
+
+	(*storage).SetGuildValue(conversation.Guild(), CustomCommandKeyPrefix+"rules", "Be nice.")
+	DeleteCustomCommand(conversation, testSender, []interface{}{"rules"}, storage, demoSender.SendMessage)
+	if !demoSender.IsEmpty() || len(CustomCommands(conversation.Guild(), storage)) != 1 {
+		t.Errorf("Only admins should be able to delete commands")
+	}
+}
+
+func TestInvalidCustomCommand(t *testing.T) {
+	storage, demoSender, conversation := customCommandsTest(true)
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	tests := [][]interface{}{
+		{HelpTrigger, "Not help"},
+		{strings.ToUpper(AddCommandTrigger), "Not an admin command"},
+		{"broken", "{{.Input"},
+		{"empty", " "},
+	}
+
+	for _, test := range tests {
+		AddCustomCommand(nil)(conversation, testSender, test, storage, demoSender.SendMessage)
+		if resultMessage, _ := demoSender.PopMessage(); resultMessage.Title != "Error" {
+			t.Errorf("%v should be invalid, got: %v", test, resultMessage)
+		}
+	}
+
+	if len(CustomCommands(conversation.Guild(), storage)) != 0 {
+		t.Errorf("Invalid commands shouldn't be created")
+	}
+}
+
+func TestCustomCommandReservedTrigger(t *testing.T) {
+	storage, demoSender, conversation := customCommandsTest(true)
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+	commands := func() []Command {
+		return []Command{{Trigger: "reload"}, {Trigger: "weather"}}
+	}
+
+	for _, trigger := range []string{"reload", "Weather", DeleteCommandTrigger} {
+		AddCustomCommand(commands)(conversation, testSender, []interface{}{trigger, "Shadowed"}, storage, demoSender.SendMessage)
+		if resultMessage, _ := demoSender.PopMessage(); resultMessage.Title != "Error" {
+			t.Errorf("%s is used by another command, got: %v", trigger, resultMessage)
+		}
+	}
+}
+
+func TestCustomCommandTemplate(t *testing.T) {
+	testSender := service.User{Name: "Test_User", ServiceID: demoservice.ServiceID}
+	tests := map[string]string{
+		"{{if .Input}}Hello {{.Input | upper}}{{else}}Hello{{end}}": "Hello A B",
+		"{{index .Parameters 1}} by {{.User}}":                      "b by Test_User",
+		"%s then %s":                                                "a then b",
+		"%s, %s and %s":                                             "a, b and ",
+		"No input":                                                  "No input",
+	}
+
+	for reply, expected := range tests {
+		if result, err := customReply(reply, testSender, []interface{}{"a", "b"}); err != nil || result != expected {
+			t.Errorf("Reply %q was %q (%v), expected %q", reply, result, err, expected)
+		}
+	}
+
+	if result, _ := customReply("%s and %s", testSender, []interface{}{"%s", "b"}); result != "%s and b" {
+		t.Errorf("Input shouldn't be substituted, got %q", result)
+	}
+}
+
+func TestListCustomCommands(t *testing.T) {
+	storage, demoSender, conversation := customCommandsTest(false)
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	ListCustomCommands(conversation, testSender, []interface{}{}, storage, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); len(resultMessage.Fields) != 0 {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+
+	(*storage).SetGuildValue(conversation.Guild(), CustomCommandKeyPrefix+"tips", "Study!")
+	(*storage).SetGuildValue(conversation.Guild(), CustomCommandKeyPrefix+"rules", "Be nice.")
+	(*storage).SetGuildValue(conversation.Guild(), PrefixKey, "!")
+
+	ListCustomCommands(conversation, testSender, []interface{}{}, storage, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+	if len(resultMessage.Fields) != 2 || resultMessage.Fields[0].Field != "rules" || resultMessage.Fields[1].Value != "Study!" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+
+	for i := 0; i < 30; i++ {
+		(*storage).SetGuildValue(conversation.Guild(), fmt.Sprintf("%s%d", CustomCommandKeyPrefix, i), strings.Repeat("a", 2000))
+	}
+
+	ListCustomCommands(conversation, testSender, []interface{}{}, storage, demoSender.SendMessage)
+	fields := 0
+	for !demoSender.IsEmpty() {
+		resultMessage, _ := demoSender.PopMessage()
+		size := len(resultMessage.Title)
+		for _, field := range resultMessage.Fields {
+			size += len(field.Field) + len(field.Value)
+		}
+
+		if len(resultMessage.Fields) > service.MaxFields || size > service.MaxMessageTotal {
+			t.Errorf("A message is too large, with %d fields and %d characters", len(resultMessage.Fields), size)
+		}
+		fields += len(resultMessage.Fields)
+	}
+
+	if fields != 32 {
+		t.Errorf("Every command should be listed, only %d were", fields)
+	}
+
+	for _, field := range GuildSettingsFields(conversation.Guild(), storage) {
+		if strings.HasPrefix(field.Field, CustomCommandKeyPrefix) {
+			t.Errorf("Custom commands shouldn't be shown as settings")
+		}
+	}
+}
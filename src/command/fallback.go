@@ -0,0 +1,99 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+// sourceFieldName is the name of the field that shows which source answered a fallback command.
+const sourceFieldName = "Source"
+
+// noSourceMessage is shown to users when no source of a fallback command has a result.
+const noSourceMessage = "No result was found from any source."
+
+// A Source is a command used by another command (such as a fallback command), along with a
+// name that is shown to users when it answers.
+type Source struct {
+	Name    string
+	Command Command
+}
+
+// FallbackConfig can be made into a command that tries several sources in order (such as
+// several dictionaries), until one of them has a result.
+type FallbackConfig struct {
+	Trigger       string      // Word which triggers this command to activate.
+	Parameters    []Parameter // How to capture words, which are given to each source.
+	Help          string      // Help message to display.
+	HelpInput     string      // Help message to display for input following command.
+	ReplyInThread bool        // See Command.ReplyInThread.
+}
+
+// Validate returns every problem found with this config.
+func (f FallbackConfig) Validate() error {
+	errs := validateTrigger(f.Trigger)
+	errs = append(errs, validateParameters(f.Parameters)...)
+	return errorsOrNil(errs)
+}
+
+// isResult returns false if msg is empty, or if it describes a failure to get a result
+// (including being rate limited).
+func isResult(msg service.Message) bool {
+	if msg.Failed {
+		return false
+	}
+	return msg.Title != "" || strings.TrimSpace(msg.Description) != "" || len(msg.Fields) > 0
+}
+
+// Command makes a command from a config, which tries each of sources in order.
+// The messages of the first source with a result are sent, and the last of them has a field
+// naming the source. If no source has a result, the messages of the last source are sent.
+func (f FallbackConfig) Command(sources []Source) (Command, error) {
+	curry := func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+		f.onMessage(sender, user, msg, storage, sink, sources)
+	}
+
+	return Command{
+		Trigger:       f.Trigger,
+		Parameters:    f.Parameters,
+		Exec:          curry,
+		Help:          f.Help,
+		HelpInput:     f.HelpInput,
+		ReplyInThread: f.ReplyInThread,
+	}, nil
+}
+
+// onMessage tries each source until one has a result, and sends out its messages.
+func (f FallbackConfig) onMessage(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), sources []Source) {
+	messages := []service.Message{}
+	for _, source := range sources {
+		messages = []service.Message{}
+		source.Command.Exec(sender, user, msg, storage, func(_ service.Conversation, message service.Message) {
+			messages = append(messages, message)
+		})
+
+		answered := false
+		for _, message := range messages {
+			answered = answered || isResult(message)
+		}
+
+		if answered {
+			last := &messages[len(messages)-1]
+			last.Fields = append(last.Fields, service.MessageField{
+				Field:  sourceFieldName,
+				Value:  source.Name,
+				Inline: true,
+			})
+			break
+		}
+	}
+
+	if len(messages) == 0 {
+		messages = append(messages, service.Message{Title: "Error", Description: noSourceMessage, Failed: true})
+	}
+
+	for _, message := range messages {
+		sink(sender, message)
+	}
+}
@@ -0,0 +1,128 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+// replyingCommand returns a command that sends each of messages.
+func replyingCommand(messages ...service.Message) Command {
+	return Command{
+		Exec: func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+			for _, message := range messages {
+				sink(sender, message)
+			}
+		},
+	}
+}
+
+func TestFallbackUsesFirstResult(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	goquerySource, err := GoQueryScraperConfig{
+		Trigger:       "define",
+		Parameters:    []Parameter{{Type: "string"}},
+		URL:           "%s",
+		TitleSelector: SelectorCapture{Template: "%s", Selectors: []string{"h3"}},
+		ReplySelector: SelectorCapture{Template: "%s", Selectors: []string{"h3"}},
+	}.CommandWithHTMLGetter(htmlTestPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := FallbackConfig{Trigger: "define", Parameters: []Parameter{{Type: "string"}}}
+	fallback, err := config.Command([]Source{
+		{Name: "Empty", Command: replyingCommand()},
+		{Name: "Failing", Command: replyingCommand(service.Message{Description: retrieveErrorMessage, Failed: true})},
+		{Name: "Rate limited", Command: replyingCommand(service.Message{Title: rateLimitedTitle, Description: "You must wait", Failed: true})},
+		{Name: "No result", Command: goquerySource},
+		{Name: "Answering", Command: replyingCommand(
+			service.Message{Title: "First", Description: "One"},
+			service.Message{Title: "Second", Description: "Two"},
+		)},
+		{Name: "Unused", Command: replyingCommand(service.Message{Title: "Unused"})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fallback.Exec(testConversation, testSender, []interface{}{"usual"}, nil, demoSender.SendMessage)
+
+	expected := []service.Message{
+		{Title: "First", Description: "One"},
+		{
+			Title:       "Second",
+			Description: "Two",
+			Fields:      []service.MessageField{{Field: sourceFieldName, Value: "Answering", Inline: true}},
+		},
+	}
+
+	for _, expectedMessage := range expected {
+		resultMessage, resultConversation := demoSender.PopMessage()
+		if diff := cmp.Diff(expectedMessage, resultMessage); diff != "" {
+			t.Errorf("Message was different: %s", diff)
+		}
+
+		if resultConversation != testConversation {
+			t.Errorf("Sender was different!")
+		}
+	}
+
+	if !demoSender.IsEmpty() {
+		t.Errorf("Too many messages!")
+	}
+}
+
+func TestFallbackWithoutResult(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	failing := service.Message{Title: "Error", Description: "No result was found", Failed: true}
+	config := FallbackConfig{Trigger: "define"}
+	fallback, _ := config.Command([]Source{
+		{Name: "Failing", Command: replyingCommand(failing)},
+		{Name: "Empty", Command: replyingCommand()},
+	})
+
+	fallback.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != noSourceMessage {
+		t.Errorf("Message was different: %v", resultMessage)
+	}
+
+	fallback, _ = config.Command([]Source{
+		{Name: "Empty", Command: replyingCommand()},
+		{Name: "Failing", Command: replyingCommand(failing)},
+	})
+
+	fallback.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+	if resultMessage, _ := demoSender.PopMessage(); !cmp.Equal(resultMessage, failing) {
+		t.Errorf("The failure of the last source should be sent: %v", resultMessage)
+	}
+}
+
+func TestIsResult(t *testing.T) {
+	tests := []struct {
+		message  service.Message
+		expected bool
+	}{
+		{service.Message{}, false},
+		{service.Message{Description: " "}, false},
+		{service.Message{Title: "Error", Description: "A word for a mistake."}, true},
+		{service.Message{Description: retrieveErrorMessage}, true},
+		{service.Message{Title: "Result", Description: "Something went wrong", Failed: true}, false},
+		{service.Message{Fields: []service.MessageField{{Field: "noun", Value: "home"}}}, true},
+	}
+
+	for _, test := range tests {
+		if got := isResult(test.message); got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.message, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package command
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// defaultFeedItems is how many items of a feed are shown, if it isn't configured.
+const defaultFeedItems = 5
+
+// defaultFeedSummary is the most characters of a summary that are shown, if it isn't configured.
+const defaultFeedSummary = 200
+
+// feedDateFormat is how the date of an item is shown.
+const feedDateFormat = "2 Jan 2006"
+
+// feedDateLayouts are the formats that dates in feeds are parsed with, in order.
+var feedDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02",
+}
+
+// FeedConfig can be made into a command that shows the newest items of an RSS or Atom feed.
+type FeedConfig struct {
+	Trigger       string        // Word which triggers this command to activate.
+	Parameters    []Parameter   // How to capture words.
+	Title         string        // The title of the message. If empty, the title of the feed is used. Can be a text/template template (see templateDelimiter), filled out like a URL.
+	URL           string        // The URL of the feed, can contain "%s" which are replaced with captures. Can instead be a text/template template.
+	Request       RequestConfig // Optionally, how to request the URL (such as the method and headers).
+	Cache         CacheConfig   // Optionally, how long to cache responses for.
+	Items         int           // How many of the newest items to show, up to service.MaxFields. If 0, 5 are shown.
+	SummaryLength int           // The most characters of each item's summary to show. If 0, 200 are shown.
+	Help          string        // Help message to display.
+	HelpInput     string        // Help message to display for input following command.
+	ReplyInThread bool          // See Command.ReplyInThread.
+}
+
+// A feedItem is an item of a feed, which is either an RSS item or an Atom entry.
+type feedItem struct {
+	Title   string
+	Link    string
+	Date    time.Time
+	Summary string // HTML describing the item.
+}
+
+// rssItem is an item of an RSS feed.
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Links       []string `xml:"link"` // Several, as an RSS feed may also have Atom links.
+	PubDate     string   `xml:"pubDate"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description string   `xml:"description"`
+}
+
+// atomLink is a link of an Atom feed or entry.
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// atomEntry is an entry of an Atom feed.
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+}
+
+// feedDocument is an RSS or Atom feed. RSS 2.0 items are in Channel, RSS 1.0 items are in
+// Items, and Atom entries are in Entries.
+type feedDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Links []string  `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// rssLink returns the first link of an RSS feed or item that isn't empty.
+func rssLink(links []string) string {
+	for _, link := range links {
+		if link = strings.TrimSpace(link); link != "" {
+			return link
+		}
+	}
+	return ""
+}
+
+// parseFeedDate returns the time described by date, or the zero time if it can't be parsed.
+func parseFeedDate(date string) time.Time {
+	date = strings.TrimSpace(date)
+	for _, layout := range feedDateLayouts {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
+
+// atomHref returns the link of an Atom feed or entry, preferring an alternate link.
+func atomHref(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// parseFeed reads an RSS or Atom feed from reader, returning its title, link, and items from
+// newest to oldest. Items without a date are after those with a date.
+func parseFeed(reader io.Reader) (title string, link string, items []feedItem, err error) {
+	decoder := xml.NewDecoder(reader)
+	// Responses are already decoded to UTF-8, whatever encoding the feed declares.
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var doc feedDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return "", "", nil, err
+	}
+
+	title, link = doc.Title, atomHref(doc.Links)
+	if doc.Channel.Title != "" {
+		title, link = doc.Channel.Title, rssLink(doc.Channel.Links)
+	}
+
+	for _, item := range append(doc.Channel.Items, doc.Items...) {
+		date := item.PubDate
+		if date == "" {
+			date = item.Date
+		}
+
+		items = append(items, feedItem{
+			Title:   item.Title,
+			Link:    rssLink(item.Links),
+			Date:    parseFeedDate(date),
+			Summary: item.Description,
+		})
+	}
+
+	for _, entry := range doc.Entries {
+		date := entry.Updated
+		if date == "" {
+			date = entry.Published
+		}
+
+		summary := entry.Summary
+		if summary == "" {
+			summary = entry.Content
+		}
+
+		items = append(items, feedItem{
+			Title:   entry.Title,
+			Link:    atomHref(entry.Links),
+			Date:    parseFeedDate(date),
+			Summary: summary,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Date.After(items[j].Date)
+	})
+	return strings.TrimSpace(title), strings.TrimSpace(link), items, nil
+}
+
+// Validate returns every problem found with this config.
+func (f FeedConfig) Validate() error {
+	errs := validateTrigger(f.Trigger)
+	errs = append(errs, validateParameters(f.Parameters)...)
+	errs = append(errs, validateURLSubstitutions(f.URL, f.Parameters, false)...)
+	errs = append(errs, f.Request.validate()...)
+	errs = append(errs, f.Cache.validate()...)
+	errs = append(errs, validateTemplate("Title", f.Title)...)
+	errs = append(errs, validateMax("Items", f.Items)...)
+	if f.SummaryLength < 0 {
+		errs = append(errs, fmt.Errorf("the SummaryLength can't be negative"))
+	}
+	return errorsOrNil(errs)
+}
+
+// Command returns a feed Command from a config.
+func (f FeedConfig) Command() (Command, error) {
+	return f.CommandWithHTMLRequester(HTMLGetWithRequest)
+}
+
+// CommandWithHTMLRequester makes a feed Command from a config, retrieving feeds using HTMLRequester.
+func (f FeedConfig) CommandWithHTMLRequester(htmlRequester HTMLRequester) (Command, error) {
+	htmlRequester = DefaultCache.HTMLRequester(f.Trigger, f.Cache, DefaultBreaker.HTMLRequester(DefaultFetcher.HTMLRequester(htmlRequester)))
+	curry := func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+		f.onMessage(sender, user, msg, storage, sink, htmlRequester)
+	}
+
+	return Command{
+		Trigger:       f.Trigger,
+		Parameters:    f.Parameters,
+		Exec:          curry,
+		Help:          f.Help,
+		HelpInput:     f.HelpInput,
+		ReplyInThread: f.ReplyInThread,
+	}, nil
+}
+
+// field returns an item as a field, with its date and its summary as markdown.
+func (f FeedConfig) field(item feedItem, base *url.URL) service.MessageField {
+	link := item.Link
+	if link != "" {
+		link = resolveURL(base, link)
+	}
+
+	lines := []string{}
+	if !item.Date.IsZero() {
+		lines = append(lines, item.Date.Format(feedDateFormat))
+	}
+
+	summaryLength := f.SummaryLength
+	if summaryLength == 0 {
+		summaryLength = defaultFeedSummary
+	}
+
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(item.Summary)); err == nil {
+		if summary := htmlToMarkdown(doc.Find("body"), base); summary != "" {
+			lines = append(lines, truncate(summaryLength, summary))
+		}
+	}
+
+	title := strings.TrimSpace(item.Title)
+	if title == "" {
+		title = link
+	}
+
+	value := strings.Join(lines, "\n")
+	if value == "" {
+		value = link
+	}
+
+	return fitField(service.MessageField{Field: title, Value: value, URL: link})
+}
+
+// onMessage processes the request, and sends out a message with a field for each item.
+// Items that don't fit within the limits of a message are left out.
+func (f FeedConfig) onMessage(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), htmlRequester HTMLRequester) {
+	msgURL, err := buildURL(f.URL, f.Parameters, msg)
+	if err != nil {
+		sink(sender, service.Message{Description: urlErrorMessage, Failed: true})
+		return
+	}
+
+	request, err := f.Request.Request(msgURL, f.Parameters, msg)
+	if err != nil {
+		sink(sender, service.Message{Description: buildRequestErrorMessage, Failed: true})
+		return
+	}
+
+	redirect, reader, err := htmlRequester(request)
+	if err != nil {
+		sink(sender, service.Message{Title: "Error", Description: requestErrorMessage(err), URL: msgURL, Failed: true})
+		return
+	}
+	defer reader.Close()
+
+	feedTitle, feedLink, items, err := parseFeed(reader)
+	if err != nil {
+		sink(sender, service.Message{Description: processErrorMessage, Failed: true})
+		return
+	}
+
+	if redirect == "" {
+		redirect = msgURL
+	}
+	base, _ := url.Parse(redirect)
+
+	limit := f.Items
+	if limit == 0 {
+		limit = defaultFeedItems
+	}
+
+	title := feedTitle
+	if isTextTemplate(f.Title) {
+		if title, err = executeTemplate(f.Title, parameterData(f.Parameters, msg)); err != nil {
+			title = feedTitle
+		}
+	} else if f.Title != "" {
+		title = f.Title
+	}
+
+	if feedLink == "" {
+		feedLink = redirect
+	}
+
+	reply := service.Message{Title: title, URL: resolveURL(base, feedLink)}
+	size := utf8.RuneCountInString(reply.Title + service.ReadMore + reply.URL)
+	for i := 0; i < len(items) && i < limit; i++ {
+		field := f.field(items[i], base)
+		size += utf8.RuneCountInString(field.Field) + field.ValueLength()
+		if size > service.MaxMessageTotal {
+			break
+		}
+		reply.Fields = append(reply.Fields, field)
+	}
+
+	if len(reply.Fields) == 0 {
+		sink(sender, service.Message{Title: "Error", Description: "No result was found", URL: msgURL, Failed: true})
+		return
+	}
+	sink(sender, reply)
+}
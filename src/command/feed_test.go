@@ -0,0 +1,235 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+	<title>Example News</title>
+	<atom:link href="https://example.com/rss.xml" rel="self"/>
+	<link>https://example.com/</link>
+	<item>
+		<title>Older</title>
+		<link>/news/older</link>
+		<pubDate>Mon, 01 Jun 2020 09:00:00 +0000</pubDate>
+		<description>An &lt;b&gt;older&lt;/b&gt; story.</description>
+	</item>
+	<item>
+		<title>Newest</title>
+		<link>https://example.com/news/newest</link>
+		<pubDate>Wed, 03 Jun 2020 09:00:00 GMT</pubDate>
+		<description><![CDATA[<p>The <a href="/about">newest</a> story.</p>]]></description>
+	</item>
+	<item>
+		<title>Middle</title>
+		<link>https://example.com/news/middle</link>
+		<pubDate>Tue, 02 Jun 2020 09:00:00 +0000</pubDate>
+		<description>A long story that goes on and on, and on and on, and on.</description>
+	</item>
+</channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Example Blog</title>
+	<link href="https://blog.example.com/feed.xml" rel="self"/>
+	<link href="https://blog.example.com/"/>
+	<entry>
+		<title>First post</title>
+		<link href="https://blog.example.com/first" rel="alternate"/>
+		<updated>2020-06-01T09:00:00Z</updated>
+		<summary>Hello world.</summary>
+	</entry>
+	<entry>
+		<title>Second post</title>
+		<link href="second"/>
+		<published>2020-06-05T09:00:00Z</published>
+		<content type="html">&lt;i&gt;Goodbye&lt;/i&gt; world.</content>
+	</entry>
+</feed>`
+
+// feedRequester returns an HTMLRequester that responds with feed, remembering the request made.
+func feedRequester(feed string, received *utils.Request) HTMLRequester {
+	return func(request utils.Request) (string, io.ReadCloser, error) {
+		*received = request
+		return request.URL, ioutil.NopCloser(strings.NewReader(feed)), nil
+	}
+}
+
+func TestFeedRSS(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := FeedConfig{
+		Trigger:       "news",
+		Parameters:    []Parameter{{Type: "string"}},
+		URL:           "https://example.com/%s.xml",
+		Items:         2,
+		SummaryLength: 50,
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("A reasonable config was invalid: %s", err)
+	}
+
+	var received utils.Request
+	command, err := config.CommandWithHTMLRequester(feedRequester(rssFeed, &received))
+	if err != nil {
+		t.Errorf("An error occurred when making a reasonable command: %s", err)
+	}
+
+	command.Exec(testConversation, testSender, []interface{}{"rss"}, nil, demoSender.SendMessage)
+	if received.URL != "https://example.com/rss.xml" {
+		t.Errorf("Unexpected request: %v", received)
+	}
+
+	resultMessage, _ := demoSender.PopMessage()
+	expected := service.Message{
+		Title: "Example News",
+		URL:   "https://example.com/",
+		Fields: []service.MessageField{
+			{
+				Field: "Newest",
+				Value: "3 Jun 2020\nThe [newest](https://example.com/about) story.",
+				URL:   "https://example.com/news/newest",
+			},
+			{
+				Field: "Middle",
+				Value: "2 Jun 2020\n" + truncate(50, "A long story that goes on and on, and on and on, and on."),
+				URL:   "https://example.com/news/middle",
+			},
+		},
+	}
+
+	if diff := cmp.Diff(resultMessage, expected); diff != "" {
+		t.Errorf("Unexpected message: %s", diff)
+	}
+}
+
+func TestFeedAtom(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := FeedConfig{
+		Trigger:    "blog",
+		Parameters: []Parameter{{Type: "string", Name: "name"}},
+		Title:      "{{.name}}'s blog",
+		URL:        "https://blog.example.com/feed.xml",
+	}
+
+	var received utils.Request
+	command, _ := config.CommandWithHTMLRequester(feedRequester(atomFeed, &received))
+	command.Exec(testConversation, testSender, []interface{}{"Sam"}, nil, demoSender.SendMessage)
+
+	resultMessage, _ := demoSender.PopMessage()
+	expected := service.Message{
+		Title: "Sam's blog",
+		URL:   "https://blog.example.com/",
+		Fields: []service.MessageField{
+			{Field: "Second post", Value: "5 Jun 2020\n*Goodbye* world.", URL: "https://blog.example.com/second"},
+			{Field: "First post", Value: "1 Jun 2020\nHello world.", URL: "https://blog.example.com/first"},
+		},
+	}
+
+	if diff := cmp.Diff(resultMessage, expected); diff != "" {
+		t.Errorf("Unexpected message: %s", diff)
+	}
+}
+
+func TestFeedInvalid(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := FeedConfig{Trigger: "news", URL: "https://example.com/feed.xml"}
+	var received utils.Request
+	command, _ := config.CommandWithHTMLRequester(feedRequester("<html>Not a feed", &received))
+	command.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+
+	if resultMessage, _ := demoSender.PopMessage(); len(resultMessage.Fields) != 0 || resultMessage.Description == "" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
+
+// longFeed returns an RSS feed with count items, which each have a long summary.
+func longFeed(count int) string {
+	var feed strings.Builder
+	feed.WriteString("<rss version=\"2.0\"><channel><title>Long News</title><link>https://example.com/</link>")
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&feed, "<item><title>Story %d</title><link>https://example.com/news/%d</link><description>%s</description></item>", i, i, strings.Repeat("word ", 300))
+	}
+	feed.WriteString("</channel></rss>")
+	return feed.String()
+}
+
+func TestFeedLimits(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	tests := []struct {
+		config   FeedConfig
+		expected int
+	}{
+		{FeedConfig{Trigger: "news", URL: "https://example.com/feed.xml"}, defaultFeedItems},
+		{FeedConfig{Trigger: "news", URL: "https://example.com/feed.xml", Items: service.MaxFields, SummaryLength: 10}, service.MaxFields},
+	}
+
+	for _, test := range tests {
+		var received utils.Request
+		command, _ := test.config.CommandWithHTMLRequester(feedRequester(longFeed(30), &received))
+		command.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+		if resultMessage, _ := demoSender.PopMessage(); len(resultMessage.Fields) != test.expected {
+			t.Errorf("Expected %d fields, got %d", test.expected, len(resultMessage.Fields))
+		}
+	}
+
+	// Long summaries only fit in a message if there are fewer of them.
+	config := FeedConfig{Trigger: "news", URL: "https://example.com/feed.xml", Items: service.MaxFields, SummaryLength: 2000}
+	var received utils.Request
+	command, _ := config.CommandWithHTMLRequester(feedRequester(longFeed(30), &received))
+	command.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+
+	resultMessage, _ := demoSender.PopMessage()
+	total := utf8.RuneCountInString(resultMessage.Title + service.ReadMore + resultMessage.URL)
+	for _, field := range resultMessage.Fields {
+		if field.ValueLength() > service.MaxFieldValue {
+			t.Errorf("A field was %d characters", field.ValueLength())
+		}
+		total += utf8.RuneCountInString(field.Field) + field.ValueLength()
+	}
+
+	if len(resultMessage.Fields) == 0 || total > service.MaxMessageTotal {
+		t.Errorf("A message had %d fields and %d characters", len(resultMessage.Fields), total)
+	}
+}
+
+func TestFeedValidate(t *testing.T) {
+	tests := []FeedConfig{
+		{Trigger: "news", URL: "https://example.com/%s.xml"},
+		{Trigger: "news", URL: "https://example.com/feed.xml", Items: -1},
+		{Trigger: "news", URL: "https://example.com/feed.xml", Items: service.MaxFields + 1},
+		{Trigger: "news", URL: "https://example.com/feed.xml", SummaryLength: -1},
+		{Trigger: "news", URL: "https://example.com/feed.xml", Title: "{{.missing"},
+	}
+
+	for _, test := range tests {
+		if err := test.Validate(); err == nil {
+			t.Errorf("Config %v should be invalid", test)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+	"github.com/temoto/robotstxt"
+)
+
+// ErrDisallowed is returned instead of requesting a page that robots.txt disallows.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// disallowedMessage is shown to users when a page can't be requested due to robots.txt.
+const disallowedMessage = "This website doesn't allow the bot to retrieve this page."
+
+// robotsTTL is how long a robots.txt is used before it is requested again.
+const robotsTTL = time.Hour
+
+// defaultRobotsAgent is the user agent checked against robots.txt, if none is configured.
+const defaultRobotsAgent = "boby"
+
+// A HostLimit limits how requests are made to a host.
+type HostLimit struct {
+	Concurrency       int     // The most requests made to the host at once. If 0, there is no limit.
+	RequestsPerSecond float64 // The most requests started each second. If 0, there is no limit.
+}
+
+// PolitenessConfig describes how requests are made to each host, so that hosts aren't
+// overwhelmed (which risks the bot being blocked).
+type PolitenessConfig struct {
+	Default   HostLimit            // Limits for hosts that aren't in Hosts.
+	Hosts     map[string]HostLimit // Limits for specific hosts, such as "example.com".
+	Robots    bool                 // If true, pages that a host's robots.txt disallows aren't requested.
+	UserAgent string               // The user agent checked against robots.txt. If empty, "boby" is used.
+}
+
+// Validate returns every problem found with the limits.
+func (p PolitenessConfig) Validate() error {
+	errs := p.Default.validate("Default")
+	for host, limit := range p.Hosts {
+		errs = append(errs, limit.validate(fmt.Sprintf("Hosts[%s]", host))...)
+	}
+	return errorsOrNil(errs)
+}
+
+// validate returns a problem if a limit is negative.
+func (h HostLimit) validate(name string) Errors {
+	errs := Errors{}
+	if h.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("the %s.Concurrency can't be negative", name))
+	}
+
+	if h.RequestsPerSecond < 0 {
+		errs = append(errs, fmt.Errorf("the %s.RequestsPerSecond can't be negative", name))
+	}
+	return errs
+}
+
+// hostQueue limits the requests made to a host. Requests wait in the order they're made.
+type hostQueue struct {
+	active    int             // Requests being made.
+	waiting   []chan struct{} // Requests waiting to be made, which are closed when it's their turn.
+	nextStart time.Time       // The earliest that another request can start.
+}
+
+// robotsEntry is a robots.txt that was requested. Requests to the same host wait for it to be
+// ready, so that robots.txt is only requested once.
+type robotsEntry struct {
+	robots  *robotstxt.RobotsData // If nil, every page is allowed.
+	expires time.Time
+	ready   chan struct{} // Closed once robots has been requested.
+}
+
+// releaser is a response body that releases its request once it is closed, so that limits
+// apply until the body has been read.
+type releaser struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+// Close closes the body, and releases its request.
+func (r *releaser) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// A Fetcher makes requests politely, by limiting the requests made to each host and
+// (optionally) honouring robots.txt. It is safe to use from multiple goroutines.
+type Fetcher struct {
+	config PolitenessConfig
+	queues map[string]*hostQueue
+	robots map[string]*robotsEntry
+	mutex  sync.Mutex // Lock when accessing config, queues or robots.
+
+	getRobots func(robotsURL string) (*robotstxt.RobotsData, error) // Used to request a robots.txt.
+	sleep     func(time.Duration)                                   // Used to wait before starting a request.
+}
+
+// DefaultFetcher is the Fetcher used by every command.
+var DefaultFetcher = NewFetcher(PolitenessConfig{})
+
+// NewFetcher returns a Fetcher using config.
+func NewFetcher(config PolitenessConfig) *Fetcher {
+	return &Fetcher{
+		config:    config,
+		queues:    make(map[string]*hostQueue),
+		robots:    make(map[string]*robotsEntry),
+		getRobots: getRobots,
+		sleep:     time.Sleep,
+	}
+}
+
+// getRobots requests and parses a robots.txt.
+func getRobots(robotsURL string) (*robotstxt.RobotsData, error) {
+	resp, err := doRequest(utils.Request{URL: robotsURL})
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	return robotstxt.FromResponse(resp)
+}
+
+// SetConfig replaces the config of f. Requests that are waiting use the previous limits.
+func (f *Fetcher) SetConfig(config PolitenessConfig) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.config = config
+	f.robots = make(map[string]*robotsEntry)
+}
+
+// limit returns the limits for host.
+func (f *Fetcher) limit(host string) HostLimit {
+	if limit, ok := f.config.Hosts[host]; ok {
+		return limit
+	}
+	return f.config.Default
+}
+
+// allowed returns false if the robots.txt of the host of page disallows it.
+// If robots.txt can't be retrieved, every page is allowed.
+func (f *Fetcher) allowed(page *url.URL) bool {
+	f.mutex.Lock()
+	if !f.config.Robots || page.Host == "" {
+		f.mutex.Unlock()
+		return true
+	}
+
+	agent := f.config.UserAgent
+	if agent == "" {
+		agent = defaultRobotsAgent
+	}
+
+	entry, ok := f.robots[page.Host]
+	if !ok || time.Now().After(entry.expires) {
+		// The entry is added before robots.txt is requested, so other requests wait for it.
+		entry = &robotsEntry{expires: time.Now().Add(robotsTTL), ready: make(chan struct{})}
+		f.robots[page.Host] = entry
+		f.mutex.Unlock()
+
+		robotsURL := url.URL{Scheme: page.Scheme, Host: page.Host, Path: "/robots.txt"}
+		if robots, err := f.getRobots(robotsURL.String()); err == nil {
+			entry.robots = robots
+		}
+		close(entry.ready)
+	} else {
+		f.mutex.Unlock()
+		<-entry.ready
+	}
+
+	return entry.robots == nil || entry.robots.TestAgent(page.RequestURI(), agent)
+}
+
+// acquire waits until a request can be made to host.
+func (f *Fetcher) acquire(host string) {
+	f.mutex.Lock()
+	limit := f.limit(host)
+	queue, ok := f.queues[host]
+	if !ok {
+		queue = &hostQueue{}
+		f.queues[host] = queue
+	}
+
+	if limit.Concurrency > 0 && (queue.active >= limit.Concurrency || len(queue.waiting) > 0) {
+		turn := make(chan struct{})
+		queue.waiting = append(queue.waiting, turn)
+		f.mutex.Unlock()
+		<-turn
+		f.mutex.Lock()
+	} else {
+		queue.active++
+	}
+
+	// Requests reserve a start time in the order they acquire, so waiting is fair.
+	now := time.Now()
+	start := now
+	if limit.RequestsPerSecond > 0 {
+		if queue.nextStart.After(start) {
+			start = queue.nextStart
+		}
+		queue.nextStart = start.Add(time.Duration(float64(time.Second) / limit.RequestsPerSecond))
+	}
+	f.mutex.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		f.sleep(wait)
+	}
+}
+
+// release allows the next waiting request to be made to host.
+func (f *Fetcher) release(host string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	queue := f.queues[host]
+	if len(queue.waiting) > 0 {
+		close(queue.waiting[0])
+		queue.waiting = queue.waiting[1:]
+		return
+	}
+
+	queue.active--
+	if queue.active == 0 && time.Now().After(queue.nextStart) {
+		delete(f.queues, host)
+	}
+}
+
+// do makes a request using try, once it is allowed and there's capacity for it.
+// The capacity is used until the body that try returns is closed.
+func (f *Fetcher) do(request utils.Request, try func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	page, err := url.Parse(request.URL)
+	if err != nil {
+		return try()
+	}
+
+	if !f.allowed(page) {
+		return nil, fmt.Errorf("%s: %w", request.URL, ErrDisallowed)
+	}
+
+	f.acquire(page.Host)
+	out, err := try()
+	if err != nil || out == nil {
+		f.release(page.Host)
+		return out, err
+	}
+	return &releaser{ReadCloser: out, release: func() { f.release(page.Host) }}, nil
+}
+
+// HTMLRequester returns an HTMLRequester that uses requester politely.
+func (f *Fetcher) HTMLRequester(requester HTMLRequester) HTMLRequester {
+	return func(request utils.Request) (redirect string, out io.ReadCloser, err error) {
+		out, err = f.do(request, func() (out io.ReadCloser, err error) {
+			redirect, out, err = requester(request)
+			return out, err
+		})
+		return redirect, out, err
+	}
+}
+
+// JSONRequester returns a JSONRequester that uses requester politely.
+func (f *Fetcher) JSONRequester(requester JSONRequester) JSONRequester {
+	return func(request utils.Request) (io.ReadCloser, error) {
+		return f.do(request, func() (io.ReadCloser, error) {
+			return requester(request)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package command
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+	"github.com/temoto/robotstxt"
+)
+
+// okRequester is an HTMLRequester that always succeeds.
+func okRequester(request utils.Request) (string, io.ReadCloser, error) {
+	return request.URL, ioutil.NopCloser(strings.NewReader("ok")), nil
+}
+
+func TestFetcherConcurrency(t *testing.T) {
+	fetcher := NewFetcher(PolitenessConfig{
+		Default: HostLimit{Concurrency: 2},
+		Hosts:   map[string]HostLimit{"slow.example.com": {Concurrency: 1}},
+	})
+
+	var mutex sync.Mutex
+	active, most := 0, 0
+	requester := fetcher.HTMLRequester(func(request utils.Request) (string, io.ReadCloser, error) {
+		mutex.Lock()
+		active++
+		if active > most {
+			most = active
+		}
+		mutex.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+		return okRequester(request)
+	})
+
+	test := func(url string, expect int) {
+		most = 0
+		var wait sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				if _, out, err := requester(utils.Request{URL: url}); err == nil {
+					out.Close()
+				}
+			}()
+		}
+		wait.Wait()
+
+		if most != expect {
+			t.Errorf("%s: expected at most %d requests at once, got %d", url, expect, most)
+		}
+	}
+
+	test("https://example.com/", 2)
+	test("https://slow.example.com/", 1)
+
+	if len(fetcher.queues) != 0 {
+		t.Errorf("Queues should be removed once they're empty")
+	}
+}
+
+func TestFetcherConcurrencyUntilClosed(t *testing.T) {
+	fetcher := NewFetcher(PolitenessConfig{Default: HostLimit{Concurrency: 1}})
+	requester := fetcher.HTMLRequester(okRequester)
+
+	_, first, err := requester(utils.Request{URL: "https://example.com/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, second, err := requester(utils.Request{URL: "https://example.com/b"}); err == nil {
+			second.Close()
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Errorf("A request shouldn't be made until the body of the last one is closed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first.Close()
+	first.Close()
+	<-done
+
+	fetcher.mutex.Lock()
+	defer fetcher.mutex.Unlock()
+	if len(fetcher.queues) != 0 {
+		t.Errorf("Closing a body twice should only release it once")
+	}
+}
+
+func TestFetcherQueueIsFair(t *testing.T) {
+	fetcher := NewFetcher(PolitenessConfig{Default: HostLimit{Concurrency: 1}})
+	fetcher.acquire("example.com")
+
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			fetcher.acquire("example.com")
+			order <- i
+			fetcher.release("example.com")
+		}(i)
+
+		// Wait for the request to be queued.
+		for {
+			fetcher.mutex.Lock()
+			queued := len(fetcher.queues["example.com"].waiting)
+			fetcher.mutex.Unlock()
+			if queued == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	fetcher.release("example.com")
+	for i := 0; i < 3; i++ {
+		if got := <-order; got != i {
+			t.Errorf("Requests should be made in the order they were queued")
+		}
+	}
+}
+
+func TestFetcherRate(t *testing.T) {
+	fetcher := NewFetcher(PolitenessConfig{Default: HostLimit{RequestsPerSecond: 2}})
+	waits := []time.Duration{}
+	fetcher.sleep = func(duration time.Duration) {
+		waits = append(waits, duration)
+	}
+
+	requester := fetcher.HTMLRequester(okRequester)
+	for i := 0; i < 3; i++ {
+		_, out, _ := requester(utils.Request{URL: "https://example.com/"})
+		out.Close()
+	}
+
+	if len(waits) != 2 || waits[0] < 400*time.Millisecond || waits[1] < 900*time.Millisecond {
+		t.Errorf("Expected requests to be spaced by half a second, got waits: %v", waits)
+	}
+}
+
+func TestFetcherRobots(t *testing.T) {
+	fetcher := NewFetcher(PolitenessConfig{Robots: true, UserAgent: "boby"})
+	requested := []string{}
+	fetcher.getRobots = func(robotsURL string) (*robotstxt.RobotsData, error) {
+		requested = append(requested, robotsURL)
+		if strings.Contains(robotsURL, "broken") {
+			return nil, errors.New("unreachable")
+		}
+		return robotstxt.FromString("User-agent: boby\nDisallow: /private\n\nUser-agent: *\nDisallow: /")
+	}
+
+	requester := fetcher.HTMLRequester(okRequester)
+	if _, _, err := requester(utils.Request{URL: "https://example.com/public?q=1"}); err != nil {
+		t.Errorf("An allowed page should be requested: %s", err)
+	}
+
+	if _, _, err := requester(utils.Request{URL: "https://example.com/private/page"}); !errors.Is(err, ErrDisallowed) {
+		t.Errorf("A disallowed page shouldn't be requested: %v", err)
+	}
+
+	if _, _, err := requester(utils.Request{URL: "https://broken.example.com/private"}); err != nil {
+		t.Errorf("If robots.txt can't be retrieved, pages should be allowed: %s", err)
+	}
+
+	if len(requested) != 2 || requested[0] != "https://example.com/robots.txt" {
+		t.Errorf("robots.txt should be requested once for each host: %v", requested)
+	}
+
+	fetcher.SetConfig(PolitenessConfig{})
+	if _, _, err := requester(utils.Request{URL: "https://example.com/private/page"}); err != nil {
+		t.Errorf("robots.txt should only be honoured if configured: %s", err)
+	}
+}
+
+func TestFetcherRobotsRequestedOnce(t *testing.T) {
+	fetcher := NewFetcher(PolitenessConfig{Robots: true})
+	var mutex sync.Mutex
+	requests := 0
+	fetcher.getRobots = func(robotsURL string) (*robotstxt.RobotsData, error) {
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+		return robotstxt.FromString("User-agent: *\nDisallow: /private")
+	}
+
+	requester := fetcher.HTMLRequester(okRequester)
+	var wait sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if _, _, err := requester(utils.Request{URL: "https://example.com/private"}); !errors.Is(err, ErrDisallowed) {
+				t.Errorf("Every request should wait for robots.txt: %v", err)
+			}
+		}()
+	}
+	wait.Wait()
+
+	if requests != 1 {
+		t.Errorf("robots.txt should be requested once, but was requested %d times", requests)
+	}
+}
+
+func TestPolitenessConfigValidate(t *testing.T) {
+	config := PolitenessConfig{
+		Default: HostLimit{Concurrency: -1},
+		Hosts:   map[string]HostLimit{"example.com": {RequestsPerSecond: -1}},
+	}
+
+	if errs, ok := config.Validate().(Errors); !ok || len(errs) != 2 {
+		t.Errorf("Expected 2 problems, got: %v", errs)
+	}
+}
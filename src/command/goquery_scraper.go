@@ -14,7 +14,6 @@ import (
 
 	"github.com/BKrajancic/boby/m/v2/src/service"
 	"github.com/BKrajancic/boby/m/v2/src/storage"
-	"github.com/BKrajancic/boby/m/v2/src/utils"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -27,23 +26,32 @@ type GoQueryScraperConfig struct {
 	ErrorURL      string          // A url to show only when there is an error.
 	URL           string          // A url to scrape from, can contain one "%s" which is replaced with the first capture group.
 	URLSuffix     string          // When adding a URL to a message, this string is appended. This is useful for including referral links.
+	Request       RequestConfig   // Optionally, how to request the URL (such as the method and headers).
+	Cache         CacheConfig     // Optionally, how long to cache responses for.
 	ReplySelector SelectorCapture // The output message's body text.
 	Fields        []GoQueryFieldCapture
 	Help          string // Help message to display.
 	HelpInput     string // Help message to display for input following command.
 	HideURL       bool   // When true, a result returns no URL. Use with caution, attribution is often required.
+	ReplyInThread bool   // See Command.ReplyInThread.
 }
 
 // GoQueryFieldCapture is used to have a selector capture for a pair of selectors.
 type GoQueryFieldCapture struct {
-	Title       SelectorCapture
-	Description SelectorCapture
+	Title          SelectorCapture
+	Description    SelectorCapture
+	HandleMultiple string // If "All", every match becomes its own field. Otherwise, there's at most one field.
+	Container      string // With "All", a selector for each match, which Title and Description select within. If empty, matches are aligned by index.
+	Max            int    // With "All", the most fields to make, up to service.MaxFields. If 0, service.MaxFields is used.
+	Numbered       bool   // With "All", when true each title is numbered, such as "1. Title".
 }
 
 // SelectorCapture will fill out a template string using webpage content selected with goquery.
 type SelectorCapture struct {
-	Template        string              // Message template to be filled out. Every %s in a template is replaced with results of selectors.
+	Template        string              // Message template to be filled out. Every %s in a template is replaced with results of selectors. Can instead be a text/template template (see templateDelimiter).
 	Selectors       []string            // What goquery captures are used to fill out the template.
+	Names           []string            // Optional names for the results of each selector, used by text/template templates.
+	Extract         []string            // How to extract each selector: "Text" (the default), "Markdown" (the inner HTML as markdown) or an attribute such as "@href". Relative URLs are resolved.
 	Replacements    []map[string]string // String replacements for each entry in selectors.
 	FullReplacement map[string]string   // String replacement that takes place on the completed selector.
 	HandleMultiple  string              // How to handle multiple captures. "Random" or "First."
@@ -55,26 +63,27 @@ type HTMLGetter = func(string) (url string, out io.ReadCloser, err error)
 // selectorCaptureToString matches all selectors and fill out template.
 // Then using HandleMultiple decide which to use.
 func (s SelectorCapture) selectorCaptureToString(doc goquery.Document) (string, error) {
-	if len(s.Selectors) == 0 || !strings.Contains(s.Template, "%s") {
+	return s.selectionToString(doc.Selection, doc.Url)
+}
+
+// selectionToString is like selectorCaptureToString, but only matches selectors within root.
+// Relative URLs are resolved against base.
+func (s SelectorCapture) selectionToString(root *goquery.Selection, base *url.URL) (string, error) {
+	if s.static() {
 		return s.Template, nil
 	}
 
-	var maxLength int64 = math.MaxInt64
-	allCaptures := make([](*(goquery.Selection)), len(s.Selectors))
-	for i, selector := range s.Selectors {
-		capture := doc.Find(selector)
-		allCaptures[i] = capture
-		captureLength := int64(capture.Length())
+	allCaptures, length := s.find(root)
 
-		if captureLength < maxLength {
-			maxLength = captureLength
-		}
-	}
-
-	// if maxLength == 0 {
+	// if length == 0 {
 	// 	return "", fmt.Errorf("There was an error retrieving information from the webpage.")
 	// }
-	maxLength--
+	return s.fill(allCaptures, s.pick(length), base)
+}
+
+// pick returns the index of the match to use out of length matches, using HandleMultiple.
+func (s SelectorCapture) pick(length int) int {
+	maxLength := int64(length) - 1
 
 	var index int = 0
 	if maxLength > 0 {
@@ -85,25 +94,74 @@ func (s SelectorCapture) selectorCaptureToString(doc goquery.Document) (string,
 			index = int(maxLength)
 		}
 	}
+	return index
+}
+
+// static returns true if the template isn't filled out using selectors.
+func (s SelectorCapture) static() bool {
+	return !isTextTemplate(s.Template) && (len(s.Selectors) == 0 || !strings.Contains(s.Template, "%s"))
+}
+
+// find returns the matches of each selector within root, and the fewest matches of any selector.
+// If the template is static, there's no limit to the matches.
+func (s SelectorCapture) find(root *goquery.Selection) ([]*goquery.Selection, int) {
+	if s.static() {
+		return nil, math.MaxInt32
+	}
+
+	length := math.MaxInt32
+	allCaptures := make([](*(goquery.Selection)), len(s.Selectors))
+	for i, selector := range s.Selectors {
+		capture := root.Find(selector)
+		allCaptures[i] = capture
+		if capture.Length() < length {
+			length = capture.Length()
+		}
+	}
+	return allCaptures, length
+}
+
+// fill fills out the template using the index-th match of each selector.
+func (s SelectorCapture) fill(allCaptures []*goquery.Selection, index int, base *url.URL) (string, error) {
+	if s.static() {
+		return s.Template, nil
+	}
 
-	tmp := make([]interface{}, len(s.Selectors))
+	values := make([]string, len(s.Selectors))
 	for i, selector := range allCaptures {
-		val := ""
 		if index < (*selector).Length() {
-			val = strings.TrimSpace(selector.Slice(int(index), int(index)+1).Text())
-			if i < len(s.Replacements) {
-				for search, replace := range s.Replacements[i] {
-					if strings.Contains(val, search) {
-						val = strings.ReplaceAll(val, search, replace)
-						break
-					}
+			values[i] = s.extract(i, selector.Slice(int(index), int(index)+1), base)
+		}
+	}
+	return s.format(values)
+}
+
+// format fills out the template with the text matched by each selector, making replacements.
+// Selectors without a match have empty text.
+func (s SelectorCapture) format(values []string) (string, error) {
+	tmp := make([]interface{}, len(values))
+	for i, val := range values {
+		if val != "" && i < len(s.Replacements) {
+			for search, replace := range s.Replacements[i] {
+				if strings.Contains(val, search) {
+					val = strings.ReplaceAll(val, search, replace)
+					break
 				}
 			}
 		}
 		tmp[i] = val
 	}
 
-	reply := fmt.Sprintf(s.Template, tmp...)
+	reply := ""
+	if isTextTemplate(s.Template) {
+		var err error
+		if reply, err = executeTemplate(s.Template, templateData(s.Names, tmp)); err != nil {
+			return "", err
+		}
+	} else {
+		reply = fmt.Sprintf(s.Template, tmp...)
+	}
+
 	for search, replace := range s.FullReplacement {
 		if strings.Contains(reply, search) {
 			reply = strings.ReplaceAll(reply, search, replace)
@@ -113,13 +171,205 @@ func (s SelectorCapture) selectorCaptureToString(doc goquery.Document) (string,
 	return reply, nil
 }
 
+// extract returns the text of a selection made by the i-th selector, as configured by Extract.
+func (s SelectorCapture) extract(i int, selection *goquery.Selection, base *url.URL) string {
+	extract := ""
+	if i < len(s.Extract) {
+		extract = s.Extract[i]
+	}
+
+	switch {
+	case strings.EqualFold(extract, "Markdown"):
+		return htmlToMarkdown(selection, base)
+	case strings.HasPrefix(extract, "@"):
+		attribute := extract[1:]
+		val, _ := selection.Attr(attribute)
+		if urlAttributes[strings.ToLower(attribute)] && val != "" {
+			return resolveURL(base, val)
+		}
+		return strings.TrimSpace(val)
+	default:
+		return strings.TrimSpace(selection.Text())
+	}
+}
+
+// validate returns a problem if the template can't be filled out by the selectors, or
+// HandleMultiple or Extract is unknown. name is used to identify this in problems.
+func (s SelectorCapture) validate(name string) Errors {
+	errs := validateTemplate(name, s.Template)
+	substitutions := strings.Count(s.Template, "%s")
+	if !isTextTemplate(s.Template) && len(s.Selectors) > 0 && substitutions > 0 && substitutions != len(s.Selectors) {
+		errs = append(errs, fmt.Errorf(
+			"the Template of %s has %d \"%%s\" but there are %d Selectors",
+			name,
+			substitutions,
+			len(s.Selectors),
+		))
+	}
+
+	if len(s.Names) > len(s.Selectors) {
+		errs = append(errs, fmt.Errorf("%s has more Names than Selectors", name))
+	}
+
+	if len(s.Extract) > len(s.Selectors) {
+		errs = append(errs, fmt.Errorf("%s has more Extract than Selectors", name))
+	}
+
+	for i, extract := range s.Extract {
+		known := extract == "" || strings.EqualFold(extract, "Text") || strings.EqualFold(extract, "Markdown")
+		if !known && !(len(extract) > 1 && strings.HasPrefix(extract, "@")) {
+			errs = append(errs, fmt.Errorf(
+				"unknown Extract[%d] \"%s\" for %s, expected Text, Markdown or an attribute such as @href",
+				i,
+				extract,
+				name,
+			))
+		}
+	}
+
+	switch s.HandleMultiple {
+	case "", "First", "Last", "Random":
+	default:
+		errs = append(errs, fmt.Errorf(
+			"unknown HandleMultiple \"%s\" for %s, expected one of: First, Last, Random",
+			s.HandleMultiple,
+			name,
+		))
+	}
+
+	return errs
+}
+
+// Validate returns every problem found with this config.
+func (g GoQueryScraperConfig) Validate() error {
+	errs := validateTrigger(g.Trigger)
+	errs = append(errs, validateParameters(g.Parameters)...)
+	errs = append(errs, validateURLSubstitutions(g.URL, g.Parameters, true)...)
+	errs = append(errs, g.Request.validate()...)
+	errs = append(errs, g.Cache.validate()...)
+	errs = append(errs, g.TitleSelector.validate("TitleSelector")...)
+	errs = append(errs, g.ReplySelector.validate("ReplySelector")...)
+	for i, field := range g.Fields {
+		errs = append(errs, field.validate(fmt.Sprintf("Fields[%d]", i))...)
+	}
+	return errorsOrNil(errs)
+}
+
+// validate returns every problem found with the selectors, HandleMultiple and Max.
+// name is used to identify this in problems.
+func (f GoQueryFieldCapture) validate(name string) Errors {
+	errs := f.Title.validate(name + ".Title")
+	errs = append(errs, f.Description.validate(name+".Description")...)
+	if f.HandleMultiple != "" && f.HandleMultiple != "All" {
+		errs = append(errs, fmt.Errorf(
+			"unknown HandleMultiple \"%s\" for %s, expected All or nothing",
+			f.HandleMultiple,
+			name,
+		))
+	}
+
+	return append(errs, validateMax(name+".Max", f.Max)...)
+}
+
+// validateMax returns a problem if max, the most fields of name, is negative or more fields than
+// a message can have.
+func validateMax(name string, max int) Errors {
+	if max < 0 {
+		return Errors{fmt.Errorf("the %s can't be negative", name)}
+	} else if max > service.MaxFields {
+		return Errors{fmt.Errorf("the %s can't be more than %d, the most fields of a message", name, service.MaxFields)}
+	}
+	return nil
+}
+
+// maxFields returns max, the most fields to make, or service.MaxFields if max is 0.
+func maxFields(max int) int {
+	if max == 0 {
+		return service.MaxFields
+	}
+	return max
+}
+
+// fieldAdder returns a function that adds a field to fields, unless its title or value is empty.
+// When numbered, each title is numbered, such as "1. Title". The function returns false once
+// there are max fields (or service.MaxFields, if max is 0).
+func fieldAdder(fields *[]service.MessageField, numbered bool, max int) func(title string, value string) bool {
+	max = maxFields(max)
+	return func(title string, value string) bool {
+		if title != "" && value != "" {
+			if numbered {
+				title = fmt.Sprintf("%d. %s", len(*fields)+1, title)
+			}
+
+			*fields = append(*fields, service.MessageField{
+				Field:  title,
+				Value:  value,
+				Inline: true,
+			})
+		}
+		return len(*fields) < max
+	}
+}
+
+// fields returns the fields captured from doc. Unless HandleMultiple is "All", there is at most
+// one field. Fields with an empty title or value are skipped.
+func (f GoQueryFieldCapture) fields(doc goquery.Document) []service.MessageField {
+	fields := make([]service.MessageField, 0)
+	add := fieldAdder(&fields, f.Numbered && f.HandleMultiple == "All", f.Max)
+
+	if f.HandleMultiple != "All" {
+		title, err1 := f.Title.selectorCaptureToString(doc)
+		value, err2 := f.Description.selectorCaptureToString(doc)
+		if err1 == nil && err2 == nil {
+			add(title, value)
+		}
+		return fields
+	}
+
+	if f.Container != "" {
+		doc.Find(f.Container).EachWithBreak(func(_ int, container *goquery.Selection) bool {
+			title, err1 := f.Title.selectionToString(container, doc.Url)
+			value, err2 := f.Description.selectionToString(container, doc.Url)
+			return err1 != nil || err2 != nil || add(title, value)
+		})
+		return fields
+	}
+
+	titles, titleCount := f.Title.find(doc.Selection)
+	values, valueCount := f.Description.find(doc.Selection)
+	count := titleCount
+	if valueCount < count {
+		count = valueCount
+	}
+
+	if count == math.MaxInt32 {
+		count = 1
+	}
+
+	for i := 0; i < count; i++ {
+		title, err1 := f.Title.fill(titles, i, doc.Url)
+		value, err2 := f.Description.fill(values, i, doc.Url)
+		if err1 == nil && err2 == nil && !add(title, value) {
+			break
+		}
+	}
+	return fields
+}
+
 // Command returns a webscraper Command from a config.
 func (g GoQueryScraperConfig) Command() (Command, error) {
-	return g.CommandWithHTMLGetter(utils.HTMLGetWithHTTP)
+	return g.CommandWithHTMLRequester(HTMLGetWithRequest)
 }
 
 // CommandWithHTMLGetter makes a scraper Command from a config, retrieving HTML pages using HTMLGetter.
+// Only the URL of Request is used.
 func (g GoQueryScraperConfig) CommandWithHTMLGetter(htmlGetter HTMLGetter) (Command, error) {
+	return g.CommandWithHTMLRequester(htmlGetterRequester(htmlGetter))
+}
+
+// CommandWithHTMLRequester makes a scraper Command from a config, retrieving HTML pages using HTMLRequester.
+func (g GoQueryScraperConfig) CommandWithHTMLRequester(htmlRequester HTMLRequester) (Command, error) {
+	htmlRequester = DefaultCache.HTMLRequester(g.Trigger, g.Cache, DefaultBreaker.HTMLRequester(DefaultFetcher.HTMLRequester(htmlRequester)))
 	curry := func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
 		g.onMessage(
 			sender,
@@ -127,39 +377,47 @@ func (g GoQueryScraperConfig) CommandWithHTMLGetter(htmlGetter HTMLGetter) (Comm
 			msg,
 			storage,
 			sink,
-			htmlGetter,
+			htmlRequester,
 		)
 	}
 
 	return Command{
-		Trigger:    g.Trigger,
-		Parameters: g.Parameters,
-		Exec:       curry,
-		Help:       g.Help,
-		HelpInput:  g.HelpInput,
+		Trigger:       g.Trigger,
+		Parameters:    g.Parameters,
+		Exec:          curry,
+		Help:          g.Help,
+		HelpInput:     g.HelpInput,
+		ReplyInThread: g.ReplyInThread,
 	}, nil
 }
 
 // onMessage processes the request, and sends out messages.
-func (g GoQueryScraperConfig) onMessage(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), htmlGetter HTMLGetter) {
-	substitutions := strings.Count(g.URL, "%s")
-	if (substitutions > 0) && (len(msg) == 0 || len(msg) < substitutions) {
+func (g GoQueryScraperConfig) onMessage(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), htmlRequester HTMLRequester) {
+	msgURL, err := buildURL(g.URL, g.Parameters, msg)
+	if err != nil {
 		sink(
 			sender,
 			service.Message{
-				Description: "An error occurred when building the url.",
+				Description: urlErrorMessage,
+				Failed:      true,
 			})
 		return
 	}
 
 	fields := make([]service.MessageField, 0)
-	msgURL := g.URL
 
-	for _, word := range msg {
-		msgURL = fmt.Sprintf(msgURL, url.PathEscape(word.(string)))
+	request, err := g.Request.Request(msgURL, g.Parameters, msg)
+	if err != nil {
+		sink(
+			sender,
+			service.Message{
+				Description: buildRequestErrorMessage,
+				Failed:      true,
+			})
+		return
 	}
 
-	redirect, htmlReader, err := htmlGetter(msgURL)
+	redirect, htmlReader, err := htmlRequester(request)
 	if err == nil {
 		defer htmlReader.Close()
 	} else {
@@ -167,7 +425,8 @@ func (g GoQueryScraperConfig) onMessage(sender service.Conversation, user servic
 			sender,
 			service.Message{
 				Title:       "Error",
-				Description: "An error occurred retrieving the webpage.",
+				Description: requestErrorMessage(err),
+				Failed:      true,
 				URL:         msgURL,
 			},
 		)
@@ -180,13 +439,20 @@ func (g GoQueryScraperConfig) onMessage(sender service.Conversation, user servic
 			sender,
 			service.Message{
 				Title:       msgURL,
-				Description: "An error occurred when processing the webpage.",
+				Description: processErrorMessage,
+				Failed:      true,
 				URL:         g.ErrorURL,
 			},
 		)
 		return
 	}
 
+	pageURL := redirect
+	if pageURL == "" {
+		pageURL = msgURL
+	}
+	doc.Url, _ = url.Parse(pageURL)
+
 	if doc.Text() == "" {
 		captures := []string{}
 		for _, item := range msg {
@@ -198,6 +464,7 @@ func (g GoQueryScraperConfig) onMessage(sender service.Conversation, user servic
 			service.Message{
 				Title:       "Error",
 				Description: fmt.Sprintf("No result was found for \"%s\"", strings.Join(captures, " ")),
+				Failed:      true,
 				URL:         g.ErrorURL,
 			},
 		)
@@ -219,20 +486,11 @@ func (g GoQueryScraperConfig) onMessage(sender service.Conversation, user servic
 	}
 
 	for _, field := range g.Fields {
-		fieldTitle, err1 := field.Title.selectorCaptureToString(*doc)
-		value, err2 := field.Description.selectorCaptureToString(*doc)
-		if err1 == nil && err2 == nil && fieldTitle != "" && value != "" {
-			fields = append(fields,
-				service.MessageField{
-					Field:  fieldTitle,
-					Value:  value,
-					Inline: true,
-				},
-			)
-		}
+		fields = append(fields, field.fields(*doc)...)
 	}
 
-	if len(fields) == 0 {
+	failed := len(fields) == 0
+	if failed {
 		fields = append(fields, service.MessageField{
 			Field: "Error",
 			Value: "No result was found",
@@ -244,6 +502,7 @@ func (g GoQueryScraperConfig) onMessage(sender service.Conversation, user servic
 		Title:       fields[0].Field,
 		Description: fields[0].Value,
 		URL:         fields[0].URL,
+		Failed:      failed,
 	}
 
 	if len(fields) > 1 {
@@ -12,6 +12,7 @@ import (
 
 	"github.com/BKrajancic/boby/m/v2/src/service"
 	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -1055,11 +1056,12 @@ func TestGoQueryScraperUnusedCapture(t *testing.T) {
 		t.Errorf("An error occurred when making a reasonable scraper!")
 	}
 
-	scraper.Exec(testConversation, testSender, []interface{}{""}, nil, demoSender.SendMessage)
+	scraper.Exec(testConversation, testSender, []interface{}{"unused"}, nil, demoSender.SendMessage)
 
+	// Input that isn't used by the URL is ignored.
 	resultMessage, resultConversation := demoSender.PopMessage()
-	if resultMessage.Description != "An error occurred retrieving the webpage." {
-		t.Errorf("An error should be thrown!")
+	if resultMessage.Description != "Heading One" {
+		t.Errorf("Unexpected message: %v", resultMessage)
 	}
 
 	if resultConversation != testConversation {
@@ -1326,3 +1328,136 @@ func TestGoQueryScraperWithCaptureHideUrl(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGoQueryScraperReplyInThread(t *testing.T) {
+	config := GoQueryScraperConfig{
+		Parameters:    []Parameter{{Type: "string"}},
+		URL:           "%s",
+		ReplyInThread: true,
+	}
+
+	scraper, err := config.CommandWithHTMLGetter(htmlTestPage)
+	if err != nil {
+		t.Fail()
+	}
+
+	if !scraper.ReplyInThread {
+		t.Errorf("ReplyInThread should be passed on to the command")
+	}
+}
+
+const dictionaryPage = `
+<html>
+<h1>Word</h1>
+<div class="sense"><span class="part">noun</span><p>A unit of language.</p></div>
+<div class="sense"><span class="part">verb</span></div>
+<div class="sense"><span class="part">verb</span><p>To express in words.</p></div>
+<div class="sense"><span class="part">interjection</span><p>Used to express agreement.</p></div>
+</html>
+`
+
+// dictionaryScraper returns a scraper of dictionaryPage with field.
+func dictionaryScraper(t *testing.T, field GoQueryFieldCapture) Command {
+	config := GoQueryScraperConfig{
+		Trigger:       "define",
+		Parameters:    []Parameter{{Type: "string"}},
+		TitleSelector: SelectorCapture{Template: "%s", Selectors: []string{"h1"}},
+		ReplySelector: SelectorCapture{Template: "Definitions"},
+		URL:           "%s",
+		Fields:        []GoQueryFieldCapture{field},
+		Help:          "This is just a test!",
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Config should be valid, but got: %s", err)
+	}
+
+	scraper, err := config.CommandWithHTMLGetter(htmlGetRemembered(dictionaryPage))
+	if err != nil {
+		t.Fail()
+	}
+	return scraper
+}
+
+func TestGoQueryScraperFieldsAllWithContainer(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	scraper := dictionaryScraper(t, GoQueryFieldCapture{
+		Title:          SelectorCapture{Template: "%s", Selectors: []string{".part"}},
+		Description:    SelectorCapture{Template: "%s", Selectors: []string{"p"}},
+		HandleMultiple: "All",
+		Container:      ".sense",
+		Numbered:       true,
+	})
+
+	scraper.Exec(testConversation, testSender, []interface{}{"word"}, nil, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+
+	expected := []service.MessageField{
+		{Field: "1. noun", Value: "A unit of language.", Inline: true},
+		{Field: "2. verb", Value: "To express in words.", Inline: true},
+		{Field: "3. interjection", Value: "Used to express agreement.", Inline: true},
+	}
+
+	if diff := cmp.Diff(expected, resultMessage.Fields); diff != "" {
+		t.Errorf("Fields were different: %s", diff)
+	}
+}
+
+func TestGoQueryScraperFieldsAllByIndex(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	scraper := dictionaryScraper(t, GoQueryFieldCapture{
+		Title:          SelectorCapture{Template: "Definition"},
+		Description:    SelectorCapture{Template: "%s", Selectors: []string{"p"}},
+		HandleMultiple: "All",
+		Max:            2,
+	})
+
+	scraper.Exec(testConversation, testSender, []interface{}{"word"}, nil, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+
+	expected := []service.MessageField{
+		{Field: "Definition", Value: "A unit of language.", Inline: true},
+		{Field: "Definition", Value: "To express in words.", Inline: true},
+	}
+
+	if diff := cmp.Diff(expected, resultMessage.Fields); diff != "" {
+		t.Errorf("Fields were different: %s", diff)
+	}
+}
+
+func TestInvalidFieldHandleMultiple(t *testing.T) {
+	tests := []GoQueryFieldCapture{
+		{HandleMultiple: "Every"},
+		{HandleMultiple: "All", Max: -1},
+		{HandleMultiple: "All", Max: service.MaxFields + 1},
+	}
+
+	for _, test := range tests {
+		if errs := test.validate("Fields[0]"); len(errs) == 0 {
+			t.Errorf("Field %v should be invalid", test)
+		}
+	}
+}
+
+func TestGoQueryScraperFieldsAllMax(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(strings.Repeat("<p>Match</p>", service.MaxFields+5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	field := GoQueryFieldCapture{
+		Title:          SelectorCapture{Template: "Title"},
+		Description:    SelectorCapture{Template: "%s", Selectors: []string{"p"}},
+		HandleMultiple: "All",
+	}
+
+	if fields := field.fields(*doc); len(fields) != service.MaxFields {
+		t.Errorf("Without a Max, there should be %d fields, got %d", service.MaxFields, len(fields))
+	}
+}
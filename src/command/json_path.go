@@ -0,0 +1,176 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonWildcard is used in a path to select every key of an object, or every item of an array.
+const jsonWildcard = "*"
+
+// A jsonPathStep selects from a JSON value, using either a key or an index.
+type jsonPathStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// parseJSONPath splits a selector into the steps used to select from a JSON value.
+//
+// Selectors are keys separated by '.', each of which can be followed by indexes such as [0].
+// Negative indexes count from the end of an array, and "*" (or [*]) selects every key or item.
+// Keys that contain '.' or '[' can be quoted, such as ["a.key"]. A leading "$" is ignored.
+// For example: data.entries[0].meanings[*].text
+func parseJSONPath(selector string) ([]jsonPathStep, error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(selector, "$"), ".")
+	steps := []jsonPathStep{}
+
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			if path == "" || path[0] == '.' || path[0] == '[' {
+				return nil, fmt.Errorf("empty key in selector \"%s\"", selector)
+			}
+
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("missing ']' in selector \"%s\"", selector)
+			}
+
+			inner := path[1:end]
+			if strings.HasPrefix(inner, "\"") {
+				key, err := strconv.Unquote(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted key %s in selector \"%s\"", inner, selector)
+				}
+				steps = append(steps, jsonPathStep{key: key, isKey: true})
+			} else if inner == jsonWildcard {
+				steps = append(steps, jsonPathStep{key: jsonWildcard})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s] in selector \"%s\"", inner, selector)
+				}
+				steps = append(steps, jsonPathStep{index: index})
+			}
+			path = path[end+1:]
+
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+
+			key := path[:end]
+			steps = append(steps, jsonPathStep{key: key, isKey: key != jsonWildcard})
+			path = path[end:]
+		}
+	}
+
+	return steps, nil
+}
+
+// selectJSON returns every value in root that matches a selector.
+// If a selector is a key of root (even if it contains '.' or '['), that key is used.
+// Missing keys and indexes match nothing, and so does a selector that can't be parsed.
+func selectJSON(root interface{}, selector string) []interface{} {
+	if dict, ok := root.(map[string]interface{}); ok {
+		if val, ok := dict[selector]; ok {
+			return []interface{}{val}
+		}
+	}
+
+	steps, err := parseJSONPath(selector)
+	if err != nil {
+		return nil
+	}
+
+	values := []interface{}{root}
+	for _, step := range steps {
+		next := []interface{}{}
+		for _, value := range values {
+			next = append(next, step.apply(value)...)
+		}
+		values = next
+	}
+	return values
+}
+
+// apply returns what this step selects from value.
+func (s jsonPathStep) apply(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if s.isKey {
+			if val, ok := value[s.key]; ok {
+				return []interface{}{val}
+			}
+		} else if s.key == jsonWildcard {
+			keys := make([]string, 0, len(value))
+			for key := range value {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			out := make([]interface{}, len(keys))
+			for i, key := range keys {
+				out[i] = value[key]
+			}
+			return out
+		}
+
+	case []interface{}:
+		if s.key == jsonWildcard {
+			return value
+		} else if !s.isKey {
+			index := s.index
+			if index < 0 {
+				index += len(value)
+			}
+
+			if index >= 0 && index < len(value) {
+				return []interface{}{value[index]}
+			}
+		}
+	}
+	return nil
+}
+
+// formatJSON returns a JSON value as text to be used in a message.
+// Numbers are written without exponents, null is empty, arrays of values are separated by
+// commas and objects are written as JSON.
+func formatJSON(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []interface{}:
+		return formatJSONValues(value)
+	default:
+		out, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(out)
+	}
+}
+
+// formatJSONValues formats several JSON values, separated by commas. Empty values are skipped.
+func formatJSONValues(values []interface{}) string {
+	out := []string{}
+	for _, value := range values {
+		if formatted := formatJSON(value); formatted != "" {
+			out = append(out, formatted)
+		}
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,78 @@
+package command
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const nestedJSON = `{
+	"data": {
+		"entries": [
+			{"word": "bahay", "meaning": "house", "frequency": 1250, "common": true},
+			{"word": "bahay-bahayan", "meaning": "playhouse", "frequency": 3.5, "common": false}
+		],
+		"source": null
+	},
+	"a.key": "dotted"
+}`
+
+func decodeJSON(t *testing.T, content string) interface{} {
+	var out interface{}
+	if err := json.Unmarshal([]byte(content), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSelectJSON(t *testing.T) {
+	root := decodeJSON(t, nestedJSON)
+
+	tests := map[string]string{
+		"data.entries[0].meaning":    "house",
+		"$.data.entries[-1].word":    "bahay-bahayan",
+		"data.entries[*].meaning":    "house, playhouse",
+		"data.entries.*.word":        "bahay, bahay-bahayan",
+		"data.entries[0].frequency":  "1250",
+		"data.entries[1].frequency":  "3.5",
+		"data.entries[0].common":     "true",
+		"data.source":                "",
+		"data.entries[2].meaning":    "",
+		"data.missing":               "",
+		"a.key":                      "dotted",
+		`["a.key"]`:                  "dotted",
+		"data.entries[0][\"word\"]":  "bahay",
+		"data.entries[0].word.extra": "",
+	}
+
+	for selector, expect := range tests {
+		if got := formatJSONValues(selectJSON(root, selector)); got != expect {
+			t.Errorf("%s: expected \"%s\", got \"%s\"", selector, expect, got)
+		}
+	}
+}
+
+func TestSelectJSONObject(t *testing.T) {
+	root := decodeJSON(t, `{"a": {"b": 1}}`)
+	if got := formatJSONValues(selectJSON(root, "a")); got != `{"b":1}` {
+		t.Errorf("Objects should be formatted as JSON, got: %s", got)
+	}
+}
+
+func TestSelectJSONTopLevelArray(t *testing.T) {
+	root := decodeJSON(t, `[{"word": "a"}, {"word": "b"}]`)
+	if got := formatJSONValues(selectJSON(root, "[1].word")); got != "b" {
+		t.Errorf("Expected b, got: %s", got)
+	}
+
+	if got := formatJSONValues(selectJSON(root, "[*].word")); got != "a, b" {
+		t.Errorf("Expected a, b, got: %s", got)
+	}
+}
+
+func TestParseJSONPathErrors(t *testing.T) {
+	for _, selector := range []string{"a..b", "a[0", "a[x]", "a.", `a["b]`} {
+		if _, err := parseJSONPath(selector); err == nil {
+			t.Errorf("Selector %s should be invalid", selector)
+		}
+	}
+}
@@ -1,12 +1,11 @@
 package command
 
 import (
-	"crypto/md5"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/url"
 	"strings"
 	"time"
 
@@ -16,21 +15,25 @@ import (
 
 // JSONGetterConfig can be used to extract from JSON into a message.
 type JSONGetterConfig struct {
-	Trigger    string          // What a message must begin with to trigger this command.
-	Parameters []Parameter     // Capture is a regexp, that is used to capture everything following 'trigger.'
-	Message    JSONCapture     // The primary title and body of a message.
-	Fields     []JSONCapture   // A message is composed of several fields. Captures is used to make fields of a message.
-	Grouped    bool            // If true, only a single message is sent, if false each entry in .
-	URL        string          // URL to retrieve a JSON from.
-	Help       string          // Message shown when help command is used.
-	HelpInput  string          // Message shown used to explain what expected user input is following trigger.
-	Delay      int             // If grouped is false, what is the delay between each message sent.
-	Token      TokenMaker      // Often an API requires a calculated API, Token is used to help create a token and append to a URL prior to requests.
-	RateLimit  RateLimitConfig // RateLimit places a limit on how frequently a user can send messages.
-}
-
-// MessagesFromJSON accepts a dict (which usually represents a JSON) and returns a sequence of messages based on the configuration.
-func (j JSONGetterConfig) MessagesFromJSON(dict map[string]interface{}) (messages []service.Message) {
+	Trigger       string          // What a message must begin with to trigger this command.
+	Parameters    []Parameter     // Capture is a regexp, that is used to capture everything following 'trigger.'
+	Message       JSONCapture     // The primary title and body of a message.
+	Fields        []JSONCapture   // A message is composed of several fields. Captures is used to make fields of a message.
+	Each          JSONEachCapture // Optionally, make a field for each element of an array. These follow Fields.
+	Grouped       bool            // If true, only a single message is sent, if false each entry in .
+	URL           string          // URL to retrieve a JSON from.
+	Request       RequestConfig   // Optionally, how to request the URL (such as the method and headers).
+	Cache         CacheConfig     // Optionally, how long to cache responses for.
+	Help          string          // Message shown when help command is used.
+	HelpInput     string          // Message shown used to explain what expected user input is following trigger.
+	Delay         int             // If grouped is false, what is the delay between each message sent.
+	Token         TokenMaker      // Often an API requires a calculated API, Token is used to help create a token and append to a URL prior to requests.
+	RateLimit     RateLimitConfig // RateLimit places a limit on how frequently a user can send messages.
+	ReplyInThread bool            // See Command.ReplyInThread.
+}
+
+// MessagesFromJSON accepts a decoded JSON (usually an object or array) and returns a sequence of messages based on the configuration.
+func (j JSONGetterConfig) MessagesFromJSON(dict interface{}) (messages []service.Message) {
 	messages = make([]service.Message, 0)
 
 	fields := make([]service.MessageField, 0)
@@ -41,6 +44,7 @@ func (j JSONGetterConfig) MessagesFromJSON(dict map[string]interface{}) (message
 			}
 		}
 	}
+	fields = append(fields, j.Each.MessageFields(dict)...)
 
 	if j.Grouped {
 		if body, err := j.Message.MessageField(dict); err == nil {
@@ -76,9 +80,46 @@ type JSONCapture struct {
 	Body  FieldCapture
 }
 
+// JSONEachCapture uses a JSONCapture on each element of an array, to make a field for each.
+type JSONEachCapture struct {
+	Path    string      // Path to an array, such as data.entries. If a path selects several values (using "*"), each value is used. If empty, nothing is captured.
+	Capture JSONCapture // Used on each element, so selectors are relative to the element. An empty selector ("") is the element itself.
+	Max     int         // The most fields to make, up to service.MaxFields. If 0, service.MaxFields is used.
+}
+
+// MessageFields returns a field for each element of the array at Path.
+// Elements that result in a field without a title or value are skipped.
+func (j JSONEachCapture) MessageFields(dict interface{}) []service.MessageField {
+	fields := make([]service.MessageField, 0)
+	if j.Path == "" {
+		return fields
+	}
+
+	elements := selectJSON(dict, j.Path)
+	if len(elements) == 1 {
+		if array, ok := elements[0].([]interface{}); ok {
+			elements = array
+		}
+	}
+
+	max := maxFields(j.Max)
+	for _, element := range elements {
+		if len(fields) == max {
+			break
+		}
+
+		if field, err := j.Capture.MessageField(element); err == nil {
+			if field.Field != "" && field.Value != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
 // MessageField uses a dict (which is a usually a reading of a JSON file), to create a MessageField.
 // Returns an error if %s is present in either the title or description even after replacements are made.
-func (j JSONCapture) MessageField(dict map[string]interface{}) (field service.MessageField, err error) {
+func (j JSONCapture) MessageField(dict interface{}) (field service.MessageField, err error) {
 	body, err := j.Body.ToStringWithMap(dict)
 	if err != nil {
 		body = j.Body.ErrorMsg
@@ -100,21 +141,27 @@ func (j JSONCapture) MessageField(dict map[string]interface{}) (field service.Me
 
 // A FieldCapture represents a template to be filled out by selectors.
 type FieldCapture struct {
-	Template  string   // Message template to be filled out. Use %s to denote text to be replaced.
-	Selectors []string // What captures to use to fill out the template
-	ErrorMsg  string   // If the template has any %s remaining, replace the entire msg with this msg.
+	Template  string   // Message template to be filled out. Use %s to denote text to be replaced. Can instead be a text/template template (see templateDelimiter).
+	Selectors []string // What captures to use to fill out the template. Each is a key, or a path such as data.entries[0].meaning.
+	Names     []string // Optional names for the values of each selector, used by text/template templates.
+	ErrorMsg  string   // If the template has any %s remaining (or a text/template template fails), replace the entire msg with this msg.
 }
 
-// ToStringWithMap uses a map to fill out the template.
-// If a key is missing, it is skipped.
-func (f FieldCapture) ToStringWithMap(dict map[string]interface{}) (out string, err error) {
+// ToStringWithMap uses a decoded JSON to fill out the template.
+// Selectors are paths (see parseJSONPath), and if several values are selected, they're
+// separated by commas. If a value is missing, it is skipped.
+func (f FieldCapture) ToStringWithMap(dict interface{}) (out string, err error) {
+	if isTextTemplate(f.Template) {
+		return f.executeTemplate(dict)
+	}
+
 	split := strings.SplitAfter(f.Template, "%s")
 	expectReplacments := len(split) - 1
 
 	replacements := 0
 	for _, selector := range f.Selectors {
-		if val, ok := dict[selector]; ok && val != "" {
-			out += fmt.Sprintf(split[replacements], val.(string))
+		if val := formatJSONValues(selectJSON(dict, selector)); val != "" {
+			out += fmt.Sprintf(split[replacements], val)
 			replacements++
 		} else {
 			break
@@ -134,11 +181,92 @@ func (f FieldCapture) ToStringWithMap(dict map[string]interface{}) (out string,
 	return out, nil
 }
 
+// executeTemplate fills out a text/template template. The JSON is available as .JSON,
+// formatted values of selectors are available in order as .Captures, and named selectors
+// are available by name (without formatting, so they can be used in loops).
+func (f FieldCapture) executeTemplate(dict interface{}) (string, error) {
+	captures := make([]interface{}, len(f.Selectors))
+	values := make([]interface{}, len(f.Selectors))
+	for i, selector := range f.Selectors {
+		selected := selectJSON(dict, selector)
+		captures[i] = formatJSONValues(selected)
+		if len(selected) == 1 {
+			values[i] = selected[0]
+		} else if len(selected) > 1 {
+			values[i] = selected
+		}
+	}
+
+	data := templateData(f.Names, values)
+	data["Captures"] = captures
+	data["JSON"] = dict
+	return executeTemplate(f.Template, data)
+}
+
 // JSONGetter will accept a string and provide a reader. This could be a file, a webpage, who cares!
 type JSONGetter = func(string) (out io.ReadCloser, err error)
 
+// validate returns a problem for each selector that isn't a valid path.
+func (f FieldCapture) validate(name string) Errors {
+	errs := validateTemplate(name, f.Template)
+	if len(f.Names) > len(f.Selectors) {
+		errs = append(errs, fmt.Errorf("%s has more Names than Selectors", name))
+	}
+
+	for _, selector := range f.Selectors {
+		if _, err := parseJSONPath(selector); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// validate returns every problem found with the title and body.
+func (j JSONCapture) validate(name string) Errors {
+	errs := j.Title.validate(name + ".Title")
+	return append(errs, j.Body.validate(name+".Body")...)
+}
+
+// Validate returns every problem found with this config.
+func (j JSONGetterConfig) Validate() error {
+	errs := validateTrigger(j.Trigger)
+	errs = append(errs, validateParameters(j.Parameters)...)
+	errs = append(errs, validateURLSubstitutions(j.URL, j.Parameters, false)...)
+	errs = append(errs, j.Request.validate()...)
+	errs = append(errs, j.Cache.validate()...)
+	errs = append(errs, j.Message.validate("Message")...)
+	for i, field := range j.Fields {
+		errs = append(errs, field.validate(fmt.Sprintf("Fields[%d]", i))...)
+	}
+	errs = append(errs, j.Each.validate("Each")...)
+	errs = append(errs, j.Token.validate()...)
+	errs = AppendError(errs, j.RateLimit.Validate())
+	return errorsOrNil(errs)
+}
+
+// validate returns every problem found with the path, capture and maximum.
+func (j JSONEachCapture) validate(name string) Errors {
+	errs := Errors{}
+	if j.Path != "" {
+		if _, err := parseJSONPath(j.Path); err != nil {
+			errs = append(errs, fmt.Errorf("%s.Path: %w", name, err))
+		}
+	}
+
+	errs = append(errs, validateMax(name+".Max", j.Max)...)
+	return append(errs, j.Capture.validate(name+".Capture")...)
+}
+
 // Command uses the config to make a Command that processes messages.
+// Only the URL of Request is used by jsonGetter.
 func (j JSONGetterConfig) Command(jsonGetter JSONGetter) (Command, error) {
+	return j.CommandWithRequester(jsonGetterRequester(jsonGetter))
+}
+
+// CommandWithRequester uses the config to make a Command that processes messages, retrieving
+// JSON using jsonRequester.
+func (j JSONGetterConfig) CommandWithRequester(jsonRequester JSONRequester) (Command, error) {
+	jsonRequester = DefaultCache.JSONRequester(j.Trigger, j.Cache, DefaultBreaker.JSONRequester(DefaultFetcher.JSONRequester(jsonRequester)))
 	curry := func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
 		j.jsonGetterFunc(
 			sender,
@@ -146,58 +274,61 @@ func (j JSONGetterConfig) Command(jsonGetter JSONGetter) (Command, error) {
 			msg,
 			storage,
 			sink,
-			jsonGetter,
+			jsonRequester,
 		)
 	}
 
 	return Command{
-		Trigger:    j.Trigger,
-		Parameters: j.Parameters,
-		Exec:       curry,
-		Help:       j.Help,
-		HelpInput:  j.HelpInput,
+		Trigger:       j.Trigger,
+		Parameters:    j.Parameters,
+		Exec:          curry,
+		Help:          j.Help,
+		HelpInput:     j.HelpInput,
+		ReplyInThread: j.ReplyInThread,
 	}, nil
 }
 
 // jsonGetterFunc processes a message.
-func (j JSONGetterConfig) jsonGetterFunc(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), jsonGetter JSONGetter) {
-	substitutions := strings.Count(j.URL, "%s")
-	noCapture := len(msg) == 0
-
-	if (substitutions > 0) && (noCapture || len(msg) < substitutions) {
-		sink(sender, service.Message{Description: "An error occurred when building the url."})
+func (j JSONGetterConfig) jsonGetterFunc(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), jsonRequester JSONRequester) {
+	msgURL, err := buildURL(j.URL, j.Parameters, msg)
+	if err != nil {
+		sink(sender, service.Message{Description: urlErrorMessage, Failed: true})
 		return
 	}
 
+	noCapture := len(msg) == 0
 	if noCapture {
 		msg = []interface{}{""}
 	}
 
-	msgURL := j.URL
-
-	replacements := strings.Count(msgURL, "%s")
-
 	// HACK: noCapture is pretty hacky.
+	token := ""
 	if !noCapture {
-		for i, word := range msg {
-			if i == replacements {
-				break
-			}
-			msgURL = strings.Replace(msgURL, "%s", url.PathEscape(word.(string)), 1)
-		}
-
 		output := []string{}
 		for _, item := range msg {
 			output = append(output, item.(string))
 		}
 
-		msgURL += j.Token.MakeToken(strings.Join(output, ""))
+		if token, err = j.Token.MakeToken(strings.Join(output, "")); err != nil {
+			sink(sender, service.Message{Description: buildRequestErrorMessage, Failed: true})
+			return
+		}
+	}
+
+	request, err := j.Request.Request(msgURL, j.Parameters, msg)
+	if err == nil {
+		err = j.Token.place(&request, token)
+	}
+
+	if err != nil {
+		sink(sender, service.Message{Description: buildRequestErrorMessage, Failed: true})
+		return
 	}
 
-	if jsonReader, err := jsonGetter(msgURL); err == nil {
+	if jsonReader, err := jsonRequester(request); err == nil {
 		defer jsonReader.Close()
 		if buf, err := ioutil.ReadAll(jsonReader); err == nil {
-			dict := make(map[string]interface{})
+			var dict interface{}
 			if err := json.Unmarshal(buf, &dict); err == nil {
 				for _, msg := range j.MessagesFromJSON(dict) {
 					sink(sender, msg)
@@ -205,25 +336,7 @@ func (j JSONGetterConfig) jsonGetterFunc(sender service.Conversation, user servi
 				}
 			}
 		}
+	} else if errors.Is(err, ErrUnavailable) || errors.Is(err, ErrDisallowed) {
+		sink(sender, service.Message{Description: requestErrorMessage(err), Failed: true})
 	}
 }
-
-// A TokenMaker is useful for creating a token that may be part of an API request.
-type TokenMaker struct {
-	Type    string // Can be MD5
-	Prefix  string // When calculating a token, what should be prepended
-	Postfix string // When calculating a token, what should be appended
-	Size    int    // Take the first 'Size' characters from the result.
-	Suffix  string // String to append after the token
-}
-
-// MakeToken will make a token from this token maker.
-// If t.type is "MD5", prefixes 'prefix' to the input, postfixes 'postfix' to the input,
-// takes the 'size' number of characters from the MD5 sum.
-func (t TokenMaker) MakeToken(input string) (out string) {
-	if t.Type == "MD5" {
-		fullString := []byte(t.Prefix + input + t.Postfix)
-		return fmt.Sprintf("%x", md5.Sum(fullString))[:t.Size] + t.Suffix
-	}
-	return t.Suffix
-}
@@ -714,3 +714,171 @@ func TestSpacesInMessage(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestReplyInThread(t *testing.T) {
+	config := JSONGetterConfig{URL: "%s", ReplyInThread: true}
+	getter, err := config.Command(jsonExamples)
+	if err != nil {
+		t.Fail()
+	}
+
+	if !getter.ReplyInThread {
+		t.Errorf("ReplyInThread should be passed on to the command")
+	}
+}
+
+func TestNestedJSON(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{
+		ServiceID:      demoSender.ID(),
+		ConversationID: "0",
+	}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := JSONGetterConfig{
+		Trigger:    "define",
+		Parameters: []Parameter{{Type: "string"}},
+		Grouped:    true,
+		Message: JSONCapture{
+			Title: FieldCapture{
+				Template:  "%s (%s)",
+				Selectors: []string{"[0].word", "[0].entries[0].rank"},
+			},
+		},
+		Fields: []JSONCapture{
+			{
+				Title: FieldCapture{Template: "Meanings"},
+				Body: FieldCapture{
+					Template:  "%s",
+					Selectors: []string{"[0].entries[*].meaning"},
+				},
+			},
+		},
+		URL: "%s",
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	getter, err := config.Command(jsonGetRemembered(`[{
+		"word": "bahay",
+		"entries": [{"meaning": "house", "rank": 1}, {"meaning": "home", "rank": 2}]
+	}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getter.Exec(testConversation, testSender, []interface{}{"bahay"}, nil, demoSender.SendMessage)
+
+	resultMessage, _ := demoSender.PopMessage()
+	if resultMessage.Title != "bahay (1)" {
+		t.Errorf("Unexpected title: %s", resultMessage.Title)
+	}
+
+	if len(resultMessage.Fields) != 1 || resultMessage.Fields[0].Value != "house, home" {
+		t.Errorf("Unexpected fields: %v", resultMessage.Fields)
+	}
+}
+
+func TestInvalidJSONSelector(t *testing.T) {
+	config := JSONGetterConfig{
+		Trigger: "j",
+		Fields:  []JSONCapture{{Body: FieldCapture{Selectors: []string{"a[0"}}}},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Errorf("An invalid selector should be a problem")
+	}
+}
+
+func TestEach(t *testing.T) {
+	const definitions = `{"definitions": [
+		{"type": "noun", "meaning": "house"},
+		{"type": "noun", "meaning": "home"},
+		{"type": "verb"},
+		{"type": "adjective", "meaning": "domestic"}
+	]}`
+
+	config := JSONGetterConfig{
+		Grouped: true,
+		Message: JSONCapture{Title: FieldCapture{Template: "Definitions"}},
+		Each: JSONEachCapture{
+			Path: "definitions",
+			Capture: JSONCapture{
+				Title: FieldCapture{Template: "%s", Selectors: []string{"type"}},
+				Body:  FieldCapture{Template: "%s", Selectors: []string{"meaning"}},
+			},
+			Max: 2,
+		},
+	}
+
+	messages := config.MessagesFromJSON(decodeJSON(t, definitions))
+	if len(messages) != 1 || len(messages[0].Fields) != 2 {
+		t.Fatalf("Expected one message with two fields, got: %v", messages)
+	}
+
+	if messages[0].Fields[1].Value != "home" {
+		t.Errorf("Unexpected field: %v", messages[0].Fields[1])
+	}
+
+	config.Grouped = false
+	config.Each.Max = 0
+	messages = config.MessagesFromJSON(decodeJSON(t, definitions))
+	if len(messages) != 4 || messages[3].Title != "adjective" || messages[3].Description != "domestic" {
+		t.Errorf("Expected a message for each element with a meaning, got: %v", messages)
+	}
+}
+
+func TestEachElementItself(t *testing.T) {
+	config := JSONGetterConfig{
+		Grouped: true,
+		Each: JSONEachCapture{
+			Path: "[*].synonyms",
+			Capture: JSONCapture{
+				Title: FieldCapture{Template: "Synonym"},
+				Body:  FieldCapture{Template: "%s", Selectors: []string{""}},
+			},
+		},
+	}
+
+	messages := config.MessagesFromJSON(decodeJSON(t, `[{"synonyms": "a"}, {"synonyms": "b"}]`))
+	if len(messages) != 1 || len(messages[0].Fields) != 2 || messages[0].Fields[1].Value != "b" {
+		t.Errorf("Unexpected messages: %v", messages)
+	}
+}
+
+func TestInvalidEach(t *testing.T) {
+	config := JSONGetterConfig{
+		Trigger: "j",
+		Each:    JSONEachCapture{Path: "a[", Max: -1},
+	}
+
+	if errs, ok := config.Validate().(Errors); !ok || len(errs) != 2 {
+		t.Errorf("Expected problems with the path and maximum")
+	}
+
+	config.Each = JSONEachCapture{Max: service.MaxFields + 1}
+	if config.Validate() == nil {
+		t.Errorf("A Max of more fields than a message can have should be a problem")
+	}
+}
+
+func TestEachMax(t *testing.T) {
+	elements := make([]interface{}, service.MaxFields+5)
+	for i := range elements {
+		elements[i] = map[string]interface{}{"word": "bahay"}
+	}
+
+	each := JSONEachCapture{
+		Path: "words",
+		Capture: JSONCapture{
+			Title: FieldCapture{Template: "Word"},
+			Body:  FieldCapture{Template: "%s", Selectors: []string{"word"}},
+		},
+	}
+
+	if fields := each.MessageFields(map[string]interface{}{"words": elements}); len(fields) != service.MaxFields {
+		t.Errorf("Without a Max, there should be %d fields, got %d", service.MaxFields, len(fields))
+	}
+}
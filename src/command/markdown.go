@@ -0,0 +1,202 @@
+package command
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// markdownIndent marks indentation of nested lists, so it isn't removed with other whitespace.
+const markdownIndent = "\x01"
+
+// codeFence starts and ends a block of preformatted text.
+const codeFence = "```"
+
+// discordMarkdownEscaper escapes text, so that it isn't mistaken for Discord's flavour of
+// markdown, which also uses "~" for strikethrough and "|" for spoilers. Other services that
+// show markdown differently would need their own escaper.
+var discordMarkdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"~", `\~`,
+	"`", "\\`",
+	"|", `\|`,
+)
+
+// spaces matches whitespace that is shown as a single space.
+var spaces = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+// blankLines matches several blank lines in a row.
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// blockElements are elements that are shown on their own lines.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true,
+	"main": true, "nav": true, "aside": true, "table": true, "dl": true, "figure": true,
+}
+
+// urlAttributes are attributes that contain a URL, which may be relative to the page.
+var urlAttributes = map[string]bool{"href": true, "src": true, "data-src": true, "data-href": true}
+
+// resolveURL returns link resolved against base. If base is nil or link is invalid, link is
+// returned as it is.
+func resolveURL(base *url.URL, link string) string {
+	if base == nil {
+		return link
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// htmlToMarkdown returns the contents of selection as Discord-flavoured markdown, keeping bold,
+// italics, underlines, strikethroughs, links and lists. Relative links are resolved against base.
+func htmlToMarkdown(selection *goquery.Selection, base *url.URL) string {
+	markdown := markdownChildren(selection, base)
+	return strings.ReplaceAll(cleanMarkdown(markdown), markdownIndent, "  ")
+}
+
+// markdownChildren returns the children of selection as markdown.
+func markdownChildren(selection *goquery.Selection, base *url.URL) string {
+	var out strings.Builder
+	selection.Contents().Each(func(_ int, child *goquery.Selection) {
+		out.WriteString(markdownNode(child, base))
+	})
+	return out.String()
+}
+
+// markdownWrap surrounds the text of content with marker, keeping surrounding whitespace
+// outside of it.
+func markdownWrap(marker string, content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+
+	start := content[:strings.Index(content, trimmed)]
+	end := content[len(start)+len(trimmed):]
+	return start + marker + trimmed + marker + end
+}
+
+// markdownNode returns a single node as markdown.
+func markdownNode(node *goquery.Selection, base *url.URL) string {
+	name := goquery.NodeName(node)
+	switch name {
+	case "#text":
+		return discordMarkdownEscaper.Replace(spaces.ReplaceAllString(node.Text(), " "))
+	case "#comment", "script", "style", "head", "template":
+		return ""
+	case "br":
+		return "\n"
+	case "hr":
+		return "\n\n---\n\n"
+	case "b", "strong":
+		return markdownWrap("**", markdownChildren(node, base))
+	case "i", "em", "cite", "dfn":
+		return markdownWrap("*", markdownChildren(node, base))
+	case "u", "ins":
+		return markdownWrap("__", markdownChildren(node, base))
+	case "s", "strike", "del":
+		return markdownWrap("~~", markdownChildren(node, base))
+	case "code", "kbd", "samp":
+		return markdownWrap("`", spaces.ReplaceAllString(node.Text(), " "))
+	case "pre":
+		return "\n" + codeFence + "\n" + strings.Trim(node.Text(), "\n") + "\n" + codeFence + "\n"
+	case "a":
+		return markdownLink(node, base)
+	case "img":
+		src, _ := node.Attr("src")
+		alt, _ := node.Attr("alt")
+		if src == "" {
+			return discordMarkdownEscaper.Replace(alt)
+		} else if alt == "" {
+			alt = "image"
+		}
+		return fmt.Sprintf("[%s](%s)", discordMarkdownEscaper.Replace(alt), resolveURL(base, src))
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return "\n\n" + markdownWrap("**", markdownChildren(node, base)) + "\n\n"
+	case "ul", "ol":
+		return "\n\n" + markdownList(node, base, name == "ol") + "\n\n"
+	case "li", "tr", "dt", "dd":
+		return "\n" + markdownChildren(node, base) + "\n"
+	case "td", "th":
+		return " " + markdownChildren(node, base) + " "
+	case "blockquote":
+		lines := strings.Split(cleanMarkdown(markdownChildren(node, base)), "\n")
+		for i, line := range lines {
+			lines[i] = "> " + line
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	}
+
+	if blockElements[name] {
+		return "\n\n" + markdownChildren(node, base) + "\n\n"
+	}
+	return markdownChildren(node, base)
+}
+
+// markdownLink returns a link as markdown. If the link has no text, its URL is used.
+func markdownLink(node *goquery.Selection, base *url.URL) string {
+	content := markdownChildren(node, base)
+	href, ok := node.Attr("href")
+	if !ok || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return content
+	}
+
+	href = resolveURL(base, href)
+	text := strings.TrimSpace(content)
+	if text == "" {
+		return href
+	}
+
+	start := content[:strings.Index(content, text)]
+	end := content[len(start)+len(text):]
+	return fmt.Sprintf("%s[%s](%s)%s", start, text, href, end)
+}
+
+// markdownList returns the items of a list as markdown, numbering them if ordered is true.
+// Nested lists are indented.
+func markdownList(list *goquery.Selection, base *url.URL, ordered bool) string {
+	items := []string{}
+	list.Children().Filter("li").Each(func(i int, item *goquery.Selection) {
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", i+1)
+		}
+
+		lines := strings.Split(cleanMarkdown(markdownChildren(item, base)), "\n")
+		for j, line := range lines {
+			if j == 0 {
+				lines[j] = marker + line
+			} else if line != "" {
+				lines[j] = markdownIndent + line
+			}
+		}
+		items = append(items, strings.Join(lines, "\n"))
+	})
+	return strings.Join(items, "\n")
+}
+
+// cleanMarkdown removes unnecessary whitespace, outside of preformatted text.
+func cleanMarkdown(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	preformatted := false
+	for i, line := range lines {
+		if strings.TrimSpace(line) == codeFence {
+			preformatted = !preformatted
+			lines[i] = codeFence
+		} else if !preformatted {
+			lines[i] = strings.TrimSpace(spaces.ReplaceAllString(line, " "))
+		}
+	}
+
+	markdown = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.Trim(markdown, "\n")
+}
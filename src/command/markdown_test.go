@@ -0,0 +1,151 @@
+package command
+
+import (
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// markdownOf returns the markdown of the body of content, with links resolved against base.
+func markdownOf(t *testing.T, content string, base string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Couldn't parse HTML: %s", err)
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		t.Fatalf("Couldn't parse URL: %s", err)
+	}
+	return htmlToMarkdown(doc.Find("body"), baseURL)
+}
+
+func TestMarkdownFormatting(t *testing.T) {
+	tests := map[string]string{
+		"<b>bold</b> and <strong>strong</strong>": "**bold** and **strong**",
+		"<i>italics</i> and <em> emphasis </em>!": "*italics* and *emphasis* !",
+		"<p>One</p><p>Two</p>":                    "One\n\nTwo",
+		"Line<br>Break":                           "Line\nBreak",
+		"<code>a * b</code>":                      "`a * b`",
+		"2 * 3_4":                                 `2 \* 3\_4`,
+		"<pre>  indented\n    code</pre>":         "```\n  indented\n    code\n```",
+		"<script>alert(1)</script>Safe":           "Safe",
+		"<blockquote>Quoted</blockquote>":         "> Quoted",
+		"<h1>Title</h1>Text":                      "**Title**\n\nText",
+	}
+
+	for input, expected := range tests {
+		if result := markdownOf(t, input, "https://example.com/"); result != expected {
+			t.Errorf("Markdown of %q was %q, expected %q", input, result, expected)
+		}
+	}
+}
+
+func TestMarkdownLinks(t *testing.T) {
+	tests := map[string]string{
+		`<a href="/about">About</a>`:                  "[About](https://example.com/about)",
+		`<a href="page.html"> Page </a>`:              " [Page](https://example.com/news/page.html) ",
+		`<a href="https://other.com/">Other</a>`:      "[Other](https://other.com/)",
+		`<a href="#top">Top</a>`:                      "Top",
+		`<a href="/empty"></a>`:                       "https://example.com/empty",
+		`<img src="cat.png" alt="A cat">`:             "[A cat](https://example.com/news/cat.png)",
+		`See <a href="/x"><b>bold link</b></a> here.`: "See [**bold link**](https://example.com/x) here.",
+	}
+
+	for input, expected := range tests {
+		result := markdownOf(t, input, "https://example.com/news/index.html")
+		if result != strings.TrimSpace(expected) {
+			t.Errorf("Markdown of %q was %q, expected %q", input, result, strings.TrimSpace(expected))
+		}
+	}
+}
+
+func TestMarkdownLists(t *testing.T) {
+	tests := map[string]string{
+		"<ul><li>One</li><li>Two</li></ul>":                            "- One\n- Two",
+		"<ol><li>First</li><li>Second</li></ol>":                       "1. First\n2. Second",
+		"<ul><li>Parent<ul><li>Child</li></ul></li><li>Next</li></ul>": "- Parent\n\n  - Child\n- Next",
+		"Before<ul><li>Item</li></ul>After":                            "Before\n\n- Item\n\nAfter",
+	}
+
+	for input, expected := range tests {
+		if result := markdownOf(t, input, "https://example.com/"); result != expected {
+			t.Errorf("Markdown of %q was %q, expected %q", input, result, expected)
+		}
+	}
+}
+
+func TestGoQueryScraperExtract(t *testing.T) {
+	const page = `
+<html>
+<h1>Heading <b>One</b></h1>
+<a class="more" href="/read/more">Read more</a>
+<img src="images/cat.png" data-id=" 42 ">
+</html>
+`
+
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := GoQueryScraperConfig{
+		Trigger:    "extract",
+		Parameters: []Parameter{{Type: "string"}},
+		TitleSelector: SelectorCapture{
+			Template:  "%s",
+			Selectors: []string{"h1"},
+			Extract:   []string{"Markdown"},
+		},
+		URL: "https://example.com/news/%s",
+		ReplySelector: SelectorCapture{
+			Template:  "%s %s %s %s",
+			Selectors: []string{"a.more", "img", "img", "a.more"},
+			Extract:   []string{"@href", "@src", "@data-id"},
+		},
+		Help: "This is just a test!",
+	}
+
+	scraper, err := config.CommandWithHTMLGetter(func(url string) (string, io.ReadCloser, error) {
+		return "", ioutil.NopCloser(strings.NewReader(page)), nil
+	})
+	if err != nil {
+		t.Errorf("An error occurred when making a reasonable scraper: %s", err)
+	}
+
+	scraper.Exec(testConversation, testSender, []interface{}{"index.html"}, nil, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+
+	if resultMessage.Title != "Heading **One**" {
+		t.Errorf("Title was %q", resultMessage.Title)
+	}
+
+	expected := "https://example.com/read/more https://example.com/news/images/cat.png 42 Read more"
+	if !strings.HasPrefix(resultMessage.Description, expected) {
+		t.Errorf("Message was %q", resultMessage.Description)
+	}
+}
+
+func TestInvalidExtract(t *testing.T) {
+	tests := []SelectorCapture{
+		{Template: "%s", Selectors: []string{"a"}, Extract: []string{"Html"}},
+		{Template: "%s", Selectors: []string{"a"}, Extract: []string{"@"}},
+		{Template: "%s", Selectors: []string{"a"}, Extract: []string{"Text", "Markdown"}},
+	}
+
+	for _, test := range tests {
+		if errs := test.validate("ReplySelector"); len(errs) == 0 {
+			t.Errorf("Extract %v should be invalid", test.Extract)
+		}
+	}
+
+	valid := SelectorCapture{Template: "%s %s", Selectors: []string{"a", "b"}, Extract: []string{"markdown", "@href"}}
+	if errs := valid.validate("ReplySelector"); len(errs) != 0 {
+		t.Errorf("Extract should be valid, but got: %v", errs)
+	}
+}
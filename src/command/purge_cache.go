@@ -0,0 +1,29 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+// PurgeCacheTrigger is a trigger to use for a PurgeCache command.
+const PurgeCacheTrigger = "purgecache"
+
+// purgeAll is used with PurgeCache to purge the responses of every command.
+const purgeAll = "all"
+
+// PurgeCache removes the cached responses of a command (or of every command, using "all"),
+// so that new requests are made. As the cache is shared by every guild, services should only
+// let owners of the bot use this.
+func PurgeCache(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+	trigger := msg[0].(string)
+	if trigger == purgeAll {
+		trigger = ""
+	}
+
+	purged := DefaultCache.Purge(trigger)
+	sink(sender, service.Message{
+		Description: fmt.Sprintf("%d cached responses have been purged.", purged),
+	})
+}
@@ -18,6 +18,9 @@ type RateLimitConfig struct {
 	ID                 string // An ID used for storage purposes.
 }
 
+// rateLimitedTitle is the title of the message sent instead of using a rate limited command.
+const rateLimitedTitle = "Please try again later."
+
 // rateLimited returns true if a message should be rate limited.
 // now and history are expected to be in unix time.
 func (r RateLimitConfig) rateLimited(now int64, history []int64) bool {
@@ -89,8 +92,9 @@ func (r RateLimitConfig) GetRateLimitedCommand(command Command) Command {
 			sink(
 				sender,
 				service.Message{
-					Title:       "Please try again later.",
+					Title:       rateLimitedTitle,
 					Description: strings.Join([]string{r.Body, countdown}, "\n"),
+					Failed:      true,
 				},
 			)
 		} else {
@@ -12,7 +12,6 @@ import (
 
 	"github.com/BKrajancic/boby/m/v2/src/service"
 	"github.com/BKrajancic/boby/m/v2/src/storage"
-	"github.com/BKrajancic/boby/m/v2/src/utils"
 )
 
 // RegexpScraperConfig is a struct that can be made into a command.
@@ -20,12 +19,138 @@ import (
 type RegexpScraperConfig struct {
 	Trigger       string
 	Parameters    []Parameter
-	TitleTemplate string // Title template that will be replaced by regex captures (using %s).
-	TitleCapture  string // Regex captures for title replacement.
-	URL           string // A url to scrape from, can contain one "%s" which is replaced with the first capture group.
-	ReplyCapture  string // Regular expression used to parse a webpage.
-	Help          string // Help message to display
-	HelpInput     string // Help message to display for input following command
+	TitleTemplate string        // Title template that will be replaced by regex captures (using %s). Can instead be a text/template template (see templateDelimiter), where captures are .Captures.
+	TitleCapture  string        // Regex captures for title replacement.
+	URL           string        // A url to scrape from, can contain one "%s" which is replaced with the first capture group. Can instead be a text/template template.
+	Request       RequestConfig // Optionally, how to request the URL (such as the method and headers).
+	Cache         CacheConfig   // Optionally, how long to cache responses for.
+	ReplyCapture  string        // Regular expression used to parse a webpage.
+	ReplyTemplate string        // Optional text/template template for the reply, where .Matches has the capture groups of each match. By default, matches are put on separate lines.
+	Groups        RegexpGroups  // Optionally, which named capture groups of ReplyCapture make up the message.
+	Help          string        // Help message to display
+	HelpInput     string        // Help message to display for input following command
+	ReplyInThread bool          // See Command.ReplyInThread.
+}
+
+// RegexpGroups maps named capture groups (such as "(?P<meaning>.*)") of a RegexpScraperConfig's
+// ReplyCapture to parts of a message. If any part is set, the message is made only of the parts
+// set, except for the title which otherwise uses TitleTemplate.
+type RegexpGroups struct {
+	Title       string   // The group used for the title, from the first match that has it.
+	Description string   // The group used for the description, from every match (on separate lines).
+	URL         string   // The group used for the URL, from the first match that has it. Relative URLs are resolved.
+	Fields      []string // Groups that each become a field named after the group, from every match.
+	FieldTitle  string   // Along with FieldValue, each match becomes a field, titled with this group.
+	FieldValue  string   // The group used for the value of each match's field.
+	MaxFields   int      // The most fields made from matches, up to service.MaxFields. If 0, service.MaxFields is used.
+}
+
+// used returns true if any part of a message is made from named groups.
+func (g RegexpGroups) used() bool {
+	return g.Title != "" || g.Description != "" || g.URL != "" || len(g.Fields) > 0 || g.FieldTitle != "" || g.FieldValue != ""
+}
+
+// validate returns a problem for each group that capture doesn't have.
+func (g RegexpGroups) validate(capture *regexp.Regexp) Errors {
+	errs := Errors{}
+	groups := map[string]string{
+		"Title":       g.Title,
+		"Description": g.Description,
+		"URL":         g.URL,
+		"FieldTitle":  g.FieldTitle,
+		"FieldValue":  g.FieldValue,
+	}
+
+	for i, group := range g.Fields {
+		groups[fmt.Sprintf("Fields[%d]", i)] = group
+	}
+
+	for part, group := range groups {
+		if group != "" && capture.SubexpIndex(group) == -1 {
+			errs = append(errs, fmt.Errorf("the Groups.%s \"%s\" isn't a named group of ReplyCapture", part, group))
+		}
+	}
+
+	if (g.FieldTitle == "") != (g.FieldValue == "") {
+		errs = append(errs, fmt.Errorf("the Groups.FieldTitle and Groups.FieldValue must be used together"))
+	}
+
+	return append(errs, validateMax("Groups.MaxFields", g.MaxFields)...)
+}
+
+// group returns the named group of each match that isn't empty.
+func group(capture *regexp.Regexp, matches [][]string, name string) []string {
+	index := capture.SubexpIndex(name)
+	values := []string{}
+	if name == "" || index == -1 {
+		return values
+	}
+
+	for _, match := range matches {
+		if value := strings.TrimSpace(match[index]); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// message returns a message made from the named groups of matches of capture.
+// If there's no title group, title is used. Relative URLs are resolved against page.
+func (g RegexpGroups) message(capture *regexp.Regexp, matches [][]string, title string, page string) service.Message {
+	msg := service.Message{
+		Title:       title,
+		Description: strings.Join(group(capture, matches, g.Description), "\n"),
+		URL:         page,
+	}
+
+	if titles := group(capture, matches, g.Title); len(titles) > 0 {
+		msg.Title = titles[0]
+	}
+
+	if urls := group(capture, matches, g.URL); len(urls) > 0 {
+		base, err := url.Parse(page)
+		if err != nil {
+			base = nil
+		}
+		msg.URL = resolveURL(base, urls[0])
+	}
+
+	for _, name := range g.Fields {
+		if values := group(capture, matches, name); len(values) > 0 {
+			msg.Fields = append(msg.Fields, service.MessageField{
+				Field:  name,
+				Value:  strings.Join(values, "\n"),
+				Inline: true,
+			})
+		}
+	}
+
+	if g.FieldTitle == "" || g.FieldValue == "" {
+		return msg
+	}
+
+	titleIndex := capture.SubexpIndex(g.FieldTitle)
+	valueIndex := capture.SubexpIndex(g.FieldValue)
+	made := 0
+	max := maxFields(g.MaxFields)
+	for _, match := range matches {
+		// Fields of Fields count towards the most fields a message can have.
+		if made >= max || len(msg.Fields) >= service.MaxFields {
+			break
+		}
+
+		field := service.MessageField{
+			Field:  strings.TrimSpace(match[titleIndex]),
+			Value:  strings.TrimSpace(match[valueIndex]),
+			Inline: true,
+		}
+
+		if field.Field != "" && field.Value != "" {
+			msg.Fields = append(msg.Fields, field)
+			made++
+		}
+	}
+	return msg
 }
 
 // GetRegexpScraperConfigs returns a set of RegexScraperConfig by reading a file.
@@ -43,55 +168,98 @@ func GetRegexpScraperConfigs(reader io.Reader) ([]RegexpScraperConfig, error) {
 
 // Command returns a webscraper command from a config, using HTTP to get a html.
 func (r RegexpScraperConfig) Command() (Command, error) {
-	return r.CommandWithHTMLGetter(utils.HTMLGetWithHTTP)
+	return r.CommandWithHTMLRequester(HTMLGetWithRequest)
+}
+
+// Validate returns every problem found with this config.
+func (r RegexpScraperConfig) Validate() error {
+	errs := validateTrigger(r.Trigger)
+	errs = append(errs, validateParameters(r.Parameters)...)
+	errs = append(errs, validateURLSubstitutions(r.URL, r.Parameters, false)...)
+	errs = append(errs, r.Request.validate()...)
+	errs = append(errs, r.Cache.validate()...)
+	errs = append(errs, validateTemplate("TitleTemplate", r.TitleTemplate)...)
+	if r.ReplyTemplate != "" {
+		if _, err := parseTemplate(r.ReplyTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("invalid template for ReplyTemplate: %w", err))
+		}
+	}
+
+	if replyCapture, err := regexp.Compile(r.ReplyCapture); err != nil {
+		errs = append(errs, fmt.Errorf("invalid ReplyCapture: %w", err))
+	} else {
+		errs = append(errs, r.Groups.validate(replyCapture)...)
+	}
+
+	if _, err := regexp.Compile(r.TitleCapture); err != nil {
+		errs = append(errs, fmt.Errorf("invalid TitleCapture: %w", err))
+	}
+
+	return errorsOrNil(errs)
 }
 
-// CommandWithHTMLGetter makes a scraper from a config.
+// CommandWithHTMLGetter makes a scraper from a config. Only the URL of Request is used.
+// An error is returned if either ReplyCapture or TitleCapture is an invalid regular expression.
 func (r RegexpScraperConfig) CommandWithHTMLGetter(htmlGetter HTMLGetter) (Command, error) {
-	webpageCapture := regexp.MustCompile(r.ReplyCapture)
-	titleCapture := regexp.MustCompile(r.TitleCapture)
+	return r.CommandWithHTMLRequester(htmlGetterRequester(htmlGetter))
+}
+
+// CommandWithHTMLRequester makes a scraper from a config, retrieving HTML pages using HTMLRequester.
+// An error is returned if either ReplyCapture or TitleCapture is an invalid regular expression.
+func (r RegexpScraperConfig) CommandWithHTMLRequester(htmlRequester HTMLRequester) (Command, error) {
+	webpageCapture, err := regexp.Compile(r.ReplyCapture)
+	if err != nil {
+		return Command{}, fmt.Errorf("invalid ReplyCapture: %w", err)
+	}
+
+	titleCapture, err := regexp.Compile(r.TitleCapture)
+	if err != nil {
+		return Command{}, fmt.Errorf("invalid TitleCapture: %w", err)
+	}
 
+	htmlRequester = DefaultCache.HTMLRequester(r.Trigger, r.Cache, DefaultBreaker.HTMLRequester(DefaultFetcher.HTMLRequester(htmlRequester)))
 	curry := func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
-		scraper(r.URL,
+		r.scraper(
 			webpageCapture,
-			r.TitleTemplate,
 			titleCapture,
 			sender,
 			user,
 			msg,
 			storage,
 			sink,
-			htmlGetter,
+			htmlRequester,
 		)
 	}
 
 	return Command{
-		Trigger:    r.Trigger,
-		Parameters: r.Parameters,
-		Exec:       curry,
-		Help:       r.Help,
-		HelpInput:  r.HelpInput,
+		Trigger:       r.Trigger,
+		Parameters:    r.Parameters,
+		Exec:          curry,
+		Help:          r.Help,
+		HelpInput:     r.HelpInput,
+		ReplyInThread: r.ReplyInThread,
 	}, nil
 }
 
 // scraper returns the received message
-func scraper(urlTemplate string, webpageCapture *regexp.Regexp, titleTemplate string, titleCapture *regexp.Regexp, sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), htmlGetter HTMLGetter) {
-	substitutions := strings.Count(urlTemplate, "%s")
-	urlPage := urlTemplate
-	if substitutions > 0 {
-		if len(msg) == 0 || len(msg) < substitutions {
-			sink(sender, service.Message{Description: "An error when building the url."})
-			return
-		}
-		for _, capture := range msg {
-			urlPage = fmt.Sprintf(urlPage, url.PathEscape(capture.(string)))
-		}
+func (r RegexpScraperConfig) scraper(webpageCapture *regexp.Regexp, titleCapture *regexp.Regexp, sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), htmlRequester HTMLRequester) {
+	urlPage, err := buildURL(r.URL, r.Parameters, msg)
+	if err != nil {
+		sink(sender, service.Message{Description: urlErrorMessage, Failed: true})
+		return
+	}
+
+	request, err := r.Request.Request(urlPage, r.Parameters, msg)
+	if err != nil {
+		sink(sender, service.Message{Description: buildRequestErrorMessage, Failed: true})
+		return
 	}
 
-	_, htmlReader, err := htmlGetter(urlPage)
+	_, htmlReader, err := htmlRequester(request)
 	if err != nil {
 		sink(sender, service.Message{
-			Description: "An error occurred retrieving the webpage.",
+			Description: requestErrorMessage(err),
+			Failed:      true,
 			URL:         urlPage,
 		})
 		return
@@ -100,7 +268,7 @@ func scraper(urlTemplate string, webpageCapture *regexp.Regexp, titleTemplate st
 	defer htmlReader.Close()
 	body, err := ioutil.ReadAll(htmlReader)
 	if err != nil {
-		sink(sender, service.Message{Description: "An error occurred when processing the webpage."})
+		sink(sender, service.Message{Description: processErrorMessage, Failed: true})
 		return
 	}
 
@@ -109,7 +277,7 @@ func scraper(urlTemplate string, webpageCapture *regexp.Regexp, titleTemplate st
 	matches := webpageCapture.FindAllStringSubmatch(bodyS, -1)
 	titleMatches := titleCapture.FindAllStringSubmatch(bodyS, -1)
 	if matches == nil {
-		sink(sender, service.Message{Description: "Could not extract data from the webpage."})
+		sink(sender, service.Message{Description: extractErrorMessage, Failed: true})
 		return
 	}
 	allCaptures := make([]string, len(matches))
@@ -118,9 +286,31 @@ func scraper(urlTemplate string, webpageCapture *regexp.Regexp, titleTemplate st
 	}
 
 	reply := strings.Join(allCaptures, "\n")
-	replyTitle := titleTemplate
+	if r.ReplyTemplate != "" {
+		groups := make([][]string, len(matches))
+		for i, captures := range matches {
+			groups[i] = captures[1:]
+		}
+
+		if reply, err = executeTemplate(r.ReplyTemplate, map[string]interface{}{"Matches": groups}); err != nil {
+			sink(sender, service.Message{Description: extractErrorMessage, Failed: true})
+			return
+		}
+	}
+
+	replyTitle := r.TitleTemplate
+	if isTextTemplate(replyTitle) {
+		titleCaptures := []interface{}{}
+		for _, captures := range titleMatches {
+			for _, captureGroup := range captures[1:] {
+				titleCaptures = append(titleCaptures, captureGroup)
+			}
+		}
 
-	if strings.Contains(replyTitle, "%s") {
+		if replyTitle, err = executeTemplate(replyTitle, templateData(nil, titleCaptures)); err != nil {
+			replyTitle = ""
+		}
+	} else if strings.Contains(replyTitle, "%s") {
 		titleCaptures := ""
 		for _, captures := range titleMatches {
 			for _, captureGroup := range captures[1:] {
@@ -133,6 +323,11 @@ func scraper(urlTemplate string, webpageCapture *regexp.Regexp, titleTemplate st
 		replyTitle = fmt.Sprintf(replyTitle, titleCaptures)
 	}
 
+	if r.Groups.used() {
+		sink(sender, r.Groups.message(webpageCapture, matches, replyTitle, urlPage))
+		return
+	}
+
 	sink(sender, service.Message{
 		Title:       replyTitle,
 		Description: reply,
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -28,8 +29,11 @@ func TestMakeScraper(t *testing.T) {
 }
 
 func TestGetBadGetHttp(t *testing.T) {
-	_, _, err := utils.HTMLGetWithHTTP("")
-	if err == nil {
+	if _, _, err := HTMLGetWithRequest(utils.Request{}); err == nil {
+		t.Fail()
+	}
+
+	if _, err := JSONGetWithRequest(utils.Request{}); err == nil {
 		t.Fail()
 	}
 }
@@ -266,7 +270,7 @@ func TestScraperNoSubstitutions(t *testing.T) {
 	scraper.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
 
 	resultMessage, resultConversation := demoSender.PopMessage()
-	if !strings.HasPrefix(resultMessage.Description, "An error when building the url.") {
+	if !strings.HasPrefix(resultMessage.Description, urlErrorMessage) {
 		t.Errorf("Message was different!")
 	}
 
@@ -376,3 +380,152 @@ func TestScraperInvalidReader(t *testing.T) {
 		t.Errorf("Sender was different!")
 	}
 }
+
+func TestScraperReplyInThread(t *testing.T) {
+	config := RegexpScraperConfig{
+		Parameters:    []Parameter{{Type: "string"}},
+		URL:           "%s",
+		ReplyCapture:  "<h1>([^<]*)</h1>",
+		ReplyInThread: true,
+	}
+
+	scraper, err := config.CommandWithHTMLGetter(htmlTestPage)
+	if err != nil {
+		t.Fail()
+	}
+
+	if !scraper.ReplyInThread {
+		t.Errorf("ReplyInThread should be passed on to the command")
+	}
+}
+
+const glossaryPage = `
+<h1>Glossary</h1>
+<a class="source" href="/sources/glossary">Source</a>
+<dt>bug</dt><dd>An error in a program.</dd>
+<dt>feature</dt><dd>Something a program does.</dd>
+<dt>patch</dt><dd>A fix for a bug.</dd>
+`
+
+func TestScraperNamedGroups(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := RegexpScraperConfig{
+		Trigger:      "glossary",
+		Parameters:   []Parameter{{Type: "string"}},
+		URL:          "https://example.com/%s",
+		ReplyCapture: `<h1>(?P<title>[^<]*)</h1>|href="(?P<link>[^"]*)"|<dt>(?P<term>[^<]*)</dt><dd>(?P<meaning>[^<]*)</dd>`,
+		Groups: RegexpGroups{
+			Title:       "title",
+			Description: "term",
+			URL:         "link",
+			FieldTitle:  "term",
+			FieldValue:  "meaning",
+			MaxFields:   2,
+		},
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Config should be valid, but got: %s", err)
+	}
+
+	scraper, err := config.CommandWithHTMLGetter(htmlGetRemembered(glossaryPage))
+	if err != nil {
+		t.Errorf("An error occurred when making a reasonable scraper!")
+	}
+
+	scraper.Exec(testConversation, testSender, []interface{}{"words"}, nil, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+
+	expected := service.Message{
+		Title:       "Glossary",
+		Description: "bug\nfeature\npatch",
+		URL:         "https://example.com/sources/glossary",
+		Fields: []service.MessageField{
+			{Field: "bug", Value: "An error in a program.", Inline: true},
+			{Field: "feature", Value: "Something a program does.", Inline: true},
+		},
+	}
+
+	if diff := cmp.Diff(expected, resultMessage); diff != "" {
+		t.Errorf("Message was different: %s", diff)
+	}
+}
+
+func TestScraperNamedGroupFields(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := RegexpScraperConfig{
+		Trigger:       "glossary",
+		Parameters:    []Parameter{{Type: "string"}},
+		URL:           "https://example.com/%s",
+		TitleTemplate: "Terms",
+		ReplyCapture:  `<dt>(?P<term>[^<]*)</dt><dd>(?P<meaning>[^<]*)</dd>`,
+		Groups:        RegexpGroups{Fields: []string{"meaning"}},
+	}
+
+	scraper, err := config.CommandWithHTMLGetter(htmlGetRemembered(glossaryPage))
+	if err != nil {
+		t.Errorf("An error occurred when making a reasonable scraper!")
+	}
+
+	scraper.Exec(testConversation, testSender, []interface{}{"words"}, nil, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+
+	expected := service.Message{
+		Title: "Terms",
+		URL:   "https://example.com/words",
+		Fields: []service.MessageField{{
+			Field:  "meaning",
+			Value:  "An error in a program.\nSomething a program does.\nA fix for a bug.",
+			Inline: true,
+		}},
+	}
+
+	if diff := cmp.Diff(expected, resultMessage); diff != "" {
+		t.Errorf("Message was different: %s", diff)
+	}
+}
+
+func TestNamedGroupsMaxFields(t *testing.T) {
+	capture := regexp.MustCompile(`<dt>(?P<term>[^<]*)</dt><dd>(?P<meaning>[^<]*)</dd>`)
+	matches := capture.FindAllStringSubmatch(strings.Repeat("<dt>bug</dt><dd>An error.</dd>", service.MaxFields+5), -1)
+
+	groups := RegexpGroups{FieldTitle: "term", FieldValue: "meaning"}
+	if msg := groups.message(capture, matches, "Terms", ""); len(msg.Fields) != service.MaxFields {
+		t.Errorf("Without MaxFields, there should be %d fields, got %d", service.MaxFields, len(msg.Fields))
+	}
+
+	groups.Fields = []string{"term"}
+	if msg := groups.message(capture, matches, "Terms", ""); len(msg.Fields) != service.MaxFields {
+		t.Errorf("Fields should count towards the most fields, got %d", len(msg.Fields))
+	}
+}
+
+func TestInvalidNamedGroups(t *testing.T) {
+	tests := []RegexpGroups{
+		{Title: "missing"},
+		{Fields: []string{"term", "missing"}},
+		{FieldTitle: "term"},
+		{FieldTitle: "term", FieldValue: "meaning", MaxFields: -1},
+		{FieldTitle: "term", FieldValue: "meaning", MaxFields: service.MaxFields + 1},
+	}
+
+	for _, test := range tests {
+		config := RegexpScraperConfig{
+			Trigger:      "glossary",
+			Parameters:   []Parameter{{Type: "string"}},
+			URL:          "https://example.com/%s",
+			ReplyCapture: `<dt>(?P<term>[^<]*)</dt><dd>(?P<meaning>[^<]*)</dd>`,
+			Groups:       test,
+		}
+
+		if err := config.Validate(); err == nil {
+			t.Errorf("Groups %v should be invalid", test)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+	"golang.org/x/net/html/charset"
+)
+
+// An HTMLRequester makes a request and returns the url of the result and its contents.
+type HTMLRequester = func(utils.Request) (url string, out io.ReadCloser, err error)
+
+// A JSONRequester makes a request and returns the JSON that was received.
+type JSONRequester = func(utils.Request) (out io.ReadCloser, err error)
+
+// requestMethods are the HTTP methods that a RequestConfig can use.
+var requestMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// RequestConfig describes how to request a page, rather than a plain GET of a URL.
+//
+// Values of Headers, Query, Cookies and Body can be text/template templates
+// (see templateDelimiter), which are filled out using the input to a command, in the same way
+// as a URL.
+type RequestConfig struct {
+	Method   string            // The HTTP method to use, such as GET or POST. If empty, GET is used.
+	Headers  map[string]string // Headers to send, such as "User-Agent" or "X-Api-Key".
+	Query    map[string]string // Query parameters to add to the URL.
+	Cookies  map[string]string // Cookies to send.
+	Body     string            // The body of the request, such as a JSON. Set a "Content-Type" header to describe it.
+	Username string            // If not empty, basic authentication is used with Username and Password.
+	Password string
+	Encoding string // The encoding of responses (such as "windows-1252"). If empty, it is detected from the response.
+}
+
+// htmlGetterRequester returns an HTMLRequester that uses getter, only using the URL of a request.
+func htmlGetterRequester(getter HTMLGetter) HTMLRequester {
+	return func(request utils.Request) (string, io.ReadCloser, error) {
+		return getter(request.URL)
+	}
+}
+
+// jsonGetterRequester returns a JSONRequester that uses getter, only using the URL of a request.
+func jsonGetterRequester(getter JSONGetter) JSONRequester {
+	return func(request utils.Request) (io.ReadCloser, error) {
+		return getter(request.URL)
+	}
+}
+
+// A StatusError is returned when a server fails to respond to a request, such as with
+// "503 Service Unavailable" or "429 Too Many Requests". These errors are usually temporary.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+// Error describes the status that was received.
+func (e StatusError) Error() string {
+	return fmt.Sprintf("unsuccessful response: %s", e.Status)
+}
+
+// A readCloser reads from one reader, and closes another.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// doRequest makes request, returning the response.
+// If the server fails to respond (with a 5xx or 429 status), a StatusError is returned.
+func doRequest(request utils.Request) (*http.Response, error) {
+	resp, err := request.Do()
+	if err == nil && (resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests) {
+		resp.Body.Close()
+		return nil, StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp, err
+}
+
+// decode returns the body of resp, decoded to UTF-8 using encoding. If encoding is empty, the
+// charset of the Content-Type header is used, or for HTML pages, a <meta> tag.
+func decode(resp *http.Response, encoding string, html bool) (io.ReadCloser, error) {
+	contentType := resp.Header.Get("Content-Type")
+	if encoding == "" && !html {
+		// Without a charset, JSON is UTF-8.
+		_, params, err := mime.ParseMediaType(contentType)
+		if encoding = params["charset"]; err != nil || encoding == "" {
+			return resp.Body, nil
+		}
+	}
+
+	if encoding == "" {
+		reader, err := charset.NewReader(resp.Body, contentType)
+		return readCloser{reader, resp.Body}, err
+	}
+
+	found, _ := charset.Lookup(encoding)
+	if found == nil {
+		return nil, fmt.Errorf("unknown encoding \"%s\"", encoding)
+	}
+	return readCloser{found.NewDecoder().Reader(resp.Body), resp.Body}, nil
+}
+
+// HTMLGetWithRequest retrieves a HTML page by making a request. The page is decoded to UTF-8.
+func HTMLGetWithRequest(request utils.Request) (redirect string, out io.ReadCloser, err error) {
+	resp, err := doRequest(request)
+	if err != nil {
+		return redirect, out, err
+	}
+
+	redirect = resp.Request.URL.String()
+	if out, err = decode(resp, request.Encoding, true); err != nil {
+		resp.Body.Close()
+	}
+	return redirect, out, err
+}
+
+// JSONGetWithRequest retrieves a JSON by making a request. The JSON is decoded to UTF-8.
+func JSONGetWithRequest(request utils.Request) (out io.ReadCloser, err error) {
+	resp, err := doRequest(request)
+	if err != nil {
+		return out, err
+	}
+
+	if out, err = decode(resp, request.Encoding, false); err != nil {
+		resp.Body.Close()
+	}
+	return out, err
+}
+
+// fill fills out text if it is a text/template template, otherwise text is returned.
+func (r RequestConfig) fill(text string, parameters []Parameter, msg []interface{}) (string, error) {
+	if !isTextTemplate(text) {
+		return text, nil
+	}
+	return executeTemplate(text, parameterData(parameters, msg))
+}
+
+// Request returns the request to make for msgURL, using the input to a command.
+func (r RequestConfig) Request(msgURL string, parameters []Parameter, msg []interface{}) (utils.Request, error) {
+	request := utils.Request{
+		Method:   strings.ToUpper(r.Method),
+		URL:      msgURL,
+		Header:   http.Header{},
+		Username: r.Username,
+		Password: r.Password,
+		Encoding: r.Encoding,
+	}
+
+	if request.Method == "" {
+		request.Method = http.MethodGet
+	}
+
+	if len(r.Query) > 0 {
+		parsed, err := url.Parse(msgURL)
+		if err != nil {
+			return request, err
+		}
+
+		query := parsed.Query()
+		for key, value := range r.Query {
+			filled, err := r.fill(value, parameters, msg)
+			if err != nil {
+				return request, fmt.Errorf("query %s: %w", key, err)
+			}
+			query.Set(key, filled)
+		}
+
+		parsed.RawQuery = query.Encode()
+		request.URL = parsed.String()
+	}
+
+	for key, value := range r.Headers {
+		filled, err := r.fill(value, parameters, msg)
+		if err != nil {
+			return request, fmt.Errorf("header %s: %w", key, err)
+		}
+		request.Header.Set(key, filled)
+	}
+
+	if len(r.Cookies) > 0 {
+		names := make([]string, 0, len(r.Cookies))
+		for name := range r.Cookies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		cookies := make([]string, len(names))
+		for i, name := range names {
+			filled, err := r.fill(r.Cookies[name], parameters, msg)
+			if err != nil {
+				return request, fmt.Errorf("cookie %s: %w", name, err)
+			}
+			cookies[i] = (&http.Cookie{Name: name, Value: filled}).String()
+		}
+		request.Header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	body, err := r.fill(r.Body, parameters, msg)
+	if err != nil {
+		return request, fmt.Errorf("body: %w", err)
+	}
+	request.Body = body
+
+	return request, nil
+}
+
+// validate returns every problem found with the method and templates.
+func (r RequestConfig) validate() Errors {
+	errs := Errors{}
+	if r.Method != "" {
+		known := false
+		for _, method := range requestMethods {
+			known = known || strings.ToUpper(r.Method) == method
+		}
+
+		if !known {
+			errs = append(errs, fmt.Errorf(
+				"unknown Request.Method \"%s\", expected one of: %s",
+				r.Method,
+				strings.Join(requestMethods, ", "),
+			))
+		}
+	}
+
+	if found, _ := charset.Lookup(r.Encoding); r.Encoding != "" && found == nil {
+		errs = append(errs, fmt.Errorf("unknown Request.Encoding \"%s\"", r.Encoding))
+	}
+
+	for key, value := range r.Headers {
+		errs = append(errs, validateTemplate(fmt.Sprintf("Request.Headers[%s]", key), value)...)
+	}
+
+	for key, value := range r.Query {
+		errs = append(errs, validateTemplate(fmt.Sprintf("Request.Query[%s]", key), value)...)
+	}
+
+	for key, value := range r.Cookies {
+		errs = append(errs, validateTemplate(fmt.Sprintf("Request.Cookies[%s]", key), value)...)
+	}
+
+	return append(errs, validateTemplate("Request.Body", r.Body)...)
+}
+
+// Descriptions of messages that commands send when they can't get a result. Commands that use
+// other commands (such as fallback commands) recognise failures using these.
+const (
+	urlErrorMessage          = "An error occurred when building the url."
+	buildRequestErrorMessage = "An error occurred when building the request."
+	processErrorMessage      = "An error occurred when processing the webpage."
+	extractErrorMessage      = "Could not extract data from the webpage."
+)
+
+// retrieveErrorMessage is shown to users when a request fails for any other reason.
+const retrieveErrorMessage = "An error occurred retrieving the webpage."
+
+// requestErrorMessage returns a description of why a request failed, to show to users.
+func requestErrorMessage(err error) string {
+	if errors.Is(err, ErrUnavailable) {
+		return unavailableMessage
+	} else if errors.Is(err, ErrDisallowed) {
+		return disallowedMessage
+	}
+	return retrieveErrorMessage
+}
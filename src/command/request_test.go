@@ -0,0 +1,228 @@
+package command
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+)
+
+func TestRequest(t *testing.T) {
+	config := RequestConfig{
+		Method:   "post",
+		Headers:  map[string]string{"User-Agent": "boby", "X-Api-Key": "secret"},
+		Query:    map[string]string{"q": "{{.word}}", "lang": "tl"},
+		Cookies:  map[string]string{"b": "2", "a": "1"},
+		Body:     `{"word": "{{.word}}"}`,
+		Username: "user",
+		Password: "pass",
+	}
+
+	parameters := []Parameter{{Type: "string", Name: "word"}}
+	request, err := config.Request("https://example.com/search?page=1", parameters, []interface{}{"a b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if request.Method != "POST" {
+		t.Errorf("Unexpected method: %s", request.Method)
+	}
+
+	if request.URL != "https://example.com/search?lang=tl&page=1&q=a+b" {
+		t.Errorf("Unexpected URL: %s", request.URL)
+	}
+
+	if request.Header.Get("X-Api-Key") != "secret" || request.Header.Get("User-Agent") != "boby" {
+		t.Errorf("Unexpected headers: %v", request.Header)
+	}
+
+	if request.Header.Get("Cookie") != "a=1; b=2" {
+		t.Errorf("Unexpected cookies: %s", request.Header.Get("Cookie"))
+	}
+
+	if request.Body != `{"word": "a b"}` {
+		t.Errorf("Unexpected body: %s", request.Body)
+	}
+
+	if request.Username != "user" || request.Password != "pass" {
+		t.Fail()
+	}
+}
+
+func TestDefaultRequest(t *testing.T) {
+	request, err := RequestConfig{}.Request("%zz", nil, nil)
+	if err != nil || request.Method != "GET" || request.URL != "%zz" || request.Body != "" {
+		t.Errorf("Without configuration, a request should be a GET of the URL as it is: %v", request)
+	}
+}
+
+func TestInvalidRequestConfig(t *testing.T) {
+	config := RequestConfig{
+		Method:  "FETCH",
+		Headers: map[string]string{"X-Api-Key": "{{.key"},
+		Body:    "{{end}}",
+	}
+
+	if errs := config.validate(); len(errs) != 3 {
+		t.Errorf("Expected 3 problems, got: %v", errs)
+	}
+
+	if errs := (RequestConfig{Encoding: "klingon"}).validate(); len(errs) != 1 {
+		t.Errorf("An unknown encoding should be a problem: %v", errs)
+	}
+
+	if errs := (RequestConfig{Encoding: "latin1"}).validate(); len(errs) != 0 {
+		t.Errorf("Encodings should be found by any of their names: %v", errs)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := map[int]bool{200: false, 404: false, 429: true, 503: true}
+	for status, failed := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+		_, _, err := HTMLGetWithRequest(utils.Request{URL: server.URL})
+		var statusErr StatusError
+		if errors.As(err, &statusErr) != failed || (failed && statusErr.StatusCode != status) {
+			t.Errorf("Status %d gave %v", status, err)
+		}
+		server.Close()
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		encoding    string
+		json        bool
+		expected    string
+	}{
+		{"text/html; charset=ISO-8859-1", "<p>caf\xe9</p>", "", false, "<p>café</p>"},
+		{"text/html", "<meta charset=\"windows-1252\"><p>\x93caf\xe9\x94</p>", "", false, `<meta charset="windows-1252"><p>“café”</p>`},
+		{"text/html; charset=utf-8", "<p>caf\xe9</p>", "windows-1252", false, "<p>café</p>"},
+		{"text/html", "<p>café</p>", "", false, "<p>café</p>"},
+		{"application/json; charset=iso-8859-1", "{\"word\": \"caf\xe9\"}", "", true, `{"word": "café"}`},
+		{"application/json", `{"word": "café"}`, "", true, `{"word": "café"}`},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", test.contentType)
+			io.WriteString(w, test.body)
+		}))
+
+		request := utils.Request{URL: server.URL, Encoding: test.encoding}
+		var reader io.ReadCloser
+		var err error
+		if test.json {
+			reader, err = JSONGetWithRequest(request)
+		} else {
+			_, reader, err = HTMLGetWithRequest(request)
+		}
+
+		if err != nil {
+			t.Errorf("Request failed: %s", err)
+		} else if body, _ := ioutil.ReadAll(reader); string(body) != test.expected {
+			t.Errorf("Body %q with %q was decoded as %q", test.body, test.contentType, body)
+		}
+		server.Close()
+	}
+}
+
+func TestJSONGetterRequest(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := JSONGetterConfig{
+		Parameters: []Parameter{{Type: "string", Name: "word"}},
+		URL:        "https://example.com/define",
+		Request: RequestConfig{
+			Method: "POST",
+			Body:   "{{.word}}",
+		},
+		Message: JSONCapture{Body: FieldCapture{Template: "%s", Selectors: []string{"result"}}},
+	}
+
+	var received utils.Request
+	command, _ := config.CommandWithRequester(func(request utils.Request) (io.ReadCloser, error) {
+		received = request
+		return ioutil.NopCloser(strings.NewReader(`{"result": "ok"}`)), nil
+	})
+
+	command.Exec(testConversation, testSender, []interface{}{"bahay"}, nil, demoSender.SendMessage)
+	if received.Method != "POST" || received.Body != "bahay" {
+		t.Errorf("Unexpected request: %v", received)
+	}
+
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "ok" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
+
+func TestGoQueryScraperRequest(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := GoQueryScraperConfig{
+		Parameters:    []Parameter{{Type: "string"}},
+		URL:           "https://example.com/%s",
+		Request:       RequestConfig{Headers: map[string]string{"Accept-Language": "tl"}},
+		TitleSelector: SelectorCapture{Template: "%s", Selectors: []string{"h1"}},
+		ReplySelector: SelectorCapture{Template: "%s", Selectors: []string{"h2"}},
+	}
+
+	var received utils.Request
+	command, _ := config.CommandWithHTMLRequester(func(request utils.Request) (string, io.ReadCloser, error) {
+		received = request
+		return htmlTestPage("usual")
+	})
+
+	command.Exec(testConversation, testSender, []interface{}{"bahay"}, nil, demoSender.SendMessage)
+	if received.URL != "https://example.com/bahay" || received.Header.Get("Accept-Language") != "tl" {
+		t.Errorf("Unexpected request: %v", received)
+	}
+
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Title != "Heading One" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
+
+func TestRegexpScraperRequest(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := RegexpScraperConfig{
+		Parameters:   []Parameter{{Type: "string", Name: "word"}},
+		URL:          "https://example.com/",
+		Request:      RequestConfig{Query: map[string]string{"q": "{{.word}}"}},
+		ReplyCapture: "<h1>(.*)</h1>",
+	}
+
+	var received utils.Request
+	command, _ := config.CommandWithHTMLRequester(func(request utils.Request) (string, io.ReadCloser, error) {
+		received = request
+		return htmlTestPage("usual")
+	})
+
+	command.Exec(testConversation, testSender, []interface{}{"bahay"}, nil, demoSender.SendMessage)
+	if received.URL != "https://example.com/?q=bahay" {
+		t.Errorf("Unexpected request: %v", received)
+	}
+
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "Heading One\nLast Heading One" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
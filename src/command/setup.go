@@ -0,0 +1,83 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+// SetupTrigger is a trigger to use for a setup command.
+const SetupTrigger = "setup"
+
+// HelpTrigger is a trigger to use for a help command.
+const HelpTrigger = "help"
+
+// OnboardedKey is the key used in storage to record that a guild has been sent an onboarding message.
+const OnboardedKey = "onboarded"
+
+// OnboardingMessage returns a message introducing the bot to a guild that uses prefix.
+func OnboardingMessage(prefix string) service.Message {
+	adminTriggers := []string{}
+	for _, cmd := range append(AdminCommands(), CustomCommandsAdmin(nil)...) {
+		adminTriggers = append(adminTriggers, fmt.Sprintf("`%s%s`", prefix, cmd.Trigger))
+	}
+
+	return service.Message{
+		Title: "Thanks for adding me!",
+		Description: fmt.Sprintf(
+			"Commands can be used by starting a message with `%s`, or by using slash commands.",
+			prefix,
+		),
+		Fields: []service.MessageField{
+			{
+				Field: "Help",
+				Value: fmt.Sprintf("Use `%s%s` or `/%s` to see every command.", prefix, HelpTrigger, HelpTrigger),
+			},
+			{
+				Field: "Admin commands",
+				Value: strings.Join(adminTriggers, ", "),
+			},
+			{
+				Field: "Setup",
+				Value: fmt.Sprintf(
+					"Admins can use `%s%s` to check for missing permissions and see this server's settings.",
+					prefix,
+					SetupTrigger,
+				),
+			},
+		},
+	}
+}
+
+// GuildSettingsFields returns a field for each setting stored for a guild.
+// Settings that are used internally (such as OnboardedKey) and custom commands are skipped.
+func GuildSettingsFields(guild service.Guild, storage *storage.Storage) []service.MessageField {
+	values := (*storage).GetGuildValues(guild)
+
+	keys := []string{}
+	for key := range values {
+		if key != OnboardedKey && !strings.HasPrefix(key, CustomCommandKeyPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fields := []service.MessageField{}
+	for _, key := range keys {
+		value := fmt.Sprintf("%v", values[key])
+		if list, ok := values[key].([]string); ok {
+			value = strings.Join(list, ", ")
+		}
+
+		if value == "" {
+			value = "None"
+		}
+
+		fields = append(fields, service.MessageField{Field: key, Value: value, Inline: true})
+	}
+
+	return fields
+}
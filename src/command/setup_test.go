@@ -0,0 +1,69 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+func TestOnboardingMessage(t *testing.T) {
+	msg := OnboardingMessage("#")
+	if !strings.Contains(msg.Description, "`#`") {
+		t.Errorf("Onboarding message should describe the prefix")
+	}
+
+	found := false
+	for _, field := range msg.Fields {
+		if strings.Contains(field.Value, "`#"+HelpTrigger+"`") {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Onboarding message should describe help")
+	}
+
+	if !strings.Contains(msg.Fields[1].Value, "`#"+SetAdminTrigger+"user`") {
+		t.Errorf("Onboarding message should list admin commands")
+	}
+}
+
+func TestGuildSettingsFields(t *testing.T) {
+	tempStorage := storage.GetTempStorage()
+	var _storage storage.Storage = &tempStorage
+	guild := service.Guild{ServiceID: "0", GuildID: "0"}
+
+	_storage.SetDefaultGuildValue(PrefixKey, "!")
+	_storage.SetGuildValue(guild, OnboardedKey, true)
+	_storage.SetAdmin(guild, "<@!1>")
+	_storage.SetAdmin(guild, "<@&2>")
+
+	fields := GuildSettingsFields(guild, &_storage)
+	if len(fields) != 2 {
+		t.Fatalf("Expected two settings, got %d", len(fields))
+	}
+
+	if fields[0].Field != storage.AdminKey || fields[0].Value != "<@!1>, <@&2>" {
+		t.Errorf("Admins should be listed")
+	}
+
+	if fields[1].Field != PrefixKey || fields[1].Value != "!" {
+		t.Errorf("Prefix should be listed")
+	}
+}
+
+func TestGuildSettingsFieldsEmptyList(t *testing.T) {
+	tempStorage := storage.GetTempStorage()
+	var _storage storage.Storage = &tempStorage
+	guild := service.Guild{ServiceID: "0", GuildID: "0"}
+
+	_storage.SetAdmin(guild, "<@!1>")
+	_storage.UnsetAdmin(guild, "<@!1>")
+
+	fields := GuildSettingsFields(guild, &_storage)
+	if len(fields) != 1 || fields[0].Value != "None" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,244 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// Templates that contain an action, which starts with "{{" and ends with "}}", are text/template
+// templates, rather than templates that use %s. These templates can use named captures,
+// conditionals, loops and the functions of templateFuncs. For example:
+//
+//	{{if .word}}{{.word | upper}}{{else}}No result{{end}}
+//
+// A "{{" without a "}}" after it is a problem, rather than text. {{"{{"}} writes "{{".
+const templateDelimiter = "{{"
+
+// templateEnd is what ends an action of a text/template template.
+const templateEnd = "}}"
+
+// maxTemplates is how many parsed templates are kept, so that templates that change,
+// such as the replies of custom commands, can't grow templates forever.
+const maxTemplates = 1000
+
+// templates are parsed templates by their text, so that each template is only parsed once,
+// when its command is validated, rather than every time it is used.
+var templates = struct {
+	sync.Mutex
+	parsed map[string]*template.Template
+}{parsed: map[string]*template.Template{}}
+
+// emptyFunc is the name of the function that writes missing and null values as nothing,
+// which is added to the end of every action that writes a value.
+const emptyFunc = "empty"
+
+// templateFuncs are the functions that can be used by templates.
+var templateFuncs = template.FuncMap{
+	"truncate":  truncate,
+	"upper":     func(value interface{}) string { return strings.ToUpper(templateText(value)) },
+	"lower":     func(value interface{}) string { return strings.ToLower(templateText(value)) },
+	"join":      join,
+	"urlescape": func(value interface{}) string { return url.PathEscape(templateText(value)) },
+	"default":   defaultValue,
+	emptyFunc:   emptyValue,
+}
+
+// isTextTemplate returns true if text should be used as a text/template template.
+func isTextTemplate(text string) bool {
+	start := strings.Index(text, templateDelimiter)
+	return start != -1 && strings.Contains(text[start+len(templateDelimiter):], templateEnd)
+}
+
+// parseTemplate parses text as a text/template template, that can use templateFuncs.
+// Templates are only parsed the first time they're used.
+func parseTemplate(text string) (*template.Template, error) {
+	templates.Lock()
+	defer templates.Unlock()
+	if tmpl, ok := templates.parsed[text]; ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := template.New("template").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, defined := range tmpl.Templates() {
+		if defined.Tree != nil {
+			writeMissingAsEmpty(defined.Tree, defined.Tree.Root)
+		}
+	}
+	if len(templates.parsed) < maxTemplates {
+		templates.parsed[text] = tmpl
+	}
+	return tmpl, nil
+}
+
+// writeMissingAsEmpty pipes every action under node that writes a value to emptyFunc, so that
+// missing and null values are written as nothing rather than as "<no value>".
+func writeMissingAsEmpty(tree *parse.Tree, node parse.Node) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			writeMissingAsEmpty(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(node.Pipe.Decl) == 0 {
+			empty := parse.NewIdentifier(emptyFunc).SetTree(tree).SetPos(node.Pos)
+			node.Pipe.Cmds = append(node.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      node.Pos,
+				Args:     []parse.Node{empty},
+			})
+		}
+	case *parse.IfNode:
+		writeMissingAsEmpty(tree, node.List)
+		writeMissingAsEmpty(tree, node.ElseList)
+	case *parse.RangeNode:
+		writeMissingAsEmpty(tree, node.List)
+		writeMissingAsEmpty(tree, node.ElseList)
+	case *parse.WithNode:
+		writeMissingAsEmpty(tree, node.List)
+		writeMissingAsEmpty(tree, node.ElseList)
+	}
+}
+
+// executeTemplate fills out a text/template template using data.
+// Missing and null values are written as nothing.
+func executeTemplate(text string, data interface{}) (string, error) {
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// validateTemplate returns a problem if a template of name is a text/template template that
+// can't be parsed, or if it has a "{{" that doesn't start an action.
+func validateTemplate(name string, text string) Errors {
+	if !isTextTemplate(text) {
+		if strings.Contains(text, templateDelimiter) {
+			return Errors{fmt.Errorf("invalid template for %s: \"%s\" without \"%s\"", name, templateDelimiter, templateEnd)}
+		}
+		return nil
+	}
+
+	if _, err := parseTemplate(text); err != nil {
+		return Errors{fmt.Errorf("invalid template for %s: %w", name, err)}
+	}
+	return nil
+}
+
+// templateData returns the data used to fill out a template from captures.
+// Captures are available in order as .Captures, and by name if they have one.
+func templateData(names []string, captures []interface{}) map[string]interface{} {
+	data := map[string]interface{}{"Captures": captures}
+	for i, name := range names {
+		if name != "" && i < len(captures) {
+			data[name] = captures[i]
+		}
+	}
+	return data
+}
+
+// parameterData returns the data used to fill out a URL template from the input to a command.
+// Input is available in order as .Parameters, and by the name of its parameter.
+func parameterData(parameters []Parameter, msg []interface{}) map[string]interface{} {
+	names := make([]string, len(parameters))
+	for i, parameter := range parameters {
+		names[i] = parameter.Name
+	}
+
+	data := templateData(names, msg)
+	data["Parameters"] = msg
+	return data
+}
+
+// templateText returns value as text to be used in a template.
+func templateText(value interface{}) string {
+	if values, ok := value.([]string); ok {
+		return strings.Join(values, ", ")
+	}
+	return formatJSON(value)
+}
+
+// truncate shortens value to at most length characters, ending with "…" if it was shortened.
+func truncate(length int, value interface{}) string {
+	text := templateText(value)
+	if length <= 0 || utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	return string([]rune(text)[:length-1]) + "…"
+}
+
+// join returns every item of values as text, separated by sep.
+func join(sep string, values interface{}) string {
+	switch values := values.(type) {
+	case []string:
+		return strings.Join(values, sep)
+	case []interface{}:
+		out := make([]string, len(values))
+		for i, value := range values {
+			out[i] = templateText(value)
+		}
+		return strings.Join(out, sep)
+	default:
+		return templateText(values)
+	}
+}
+
+// emptyValue returns value, unless it is missing or null, in which case "" is returned.
+func emptyValue(value interface{}) interface{} {
+	if value == nil {
+		return ""
+	}
+	return value
+}
+
+// defaultValue returns value, unless it is empty, in which case def is returned.
+func defaultValue(def interface{}, value interface{}) interface{} {
+	if templateText(value) == "" {
+		return def
+	}
+	return value
+}
+
+// fillURL fills out a text/template URL template using the input to a command.
+// Input isn't escaped, so templates should use urlescape where it's needed.
+func fillURL(urlTemplate string, parameters []Parameter, msg []interface{}) (string, error) {
+	return executeTemplate(urlTemplate, parameterData(parameters, msg))
+}
+
+// buildURL returns the URL of a command for its input. If pattern is a text/template template
+// it is filled out using fillURL, otherwise each "%s" is replaced with a word of input, which is
+// escaped. If there are fewer words than "%s", an error is returned.
+func buildURL(pattern string, parameters []Parameter, msg []interface{}) (string, error) {
+	if isTextTemplate(pattern) {
+		return fillURL(pattern, parameters, msg)
+	}
+
+	substitutions := strings.Count(pattern, "%s")
+	if substitutions > len(msg) {
+		return "", fmt.Errorf("not enough input to build the url")
+	}
+
+	built := pattern
+	for _, word := range msg[:substitutions] {
+		built = strings.Replace(built, "%s", url.PathEscape(fmt.Sprint(word)), 1)
+	}
+	return built, nil
+}
@@ -0,0 +1,250 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+)
+
+func TestExecuteTemplate(t *testing.T) {
+	data := map[string]interface{}{
+		"word":     "bahay",
+		"meanings": []interface{}{"house", "home"},
+		"long":     "abcdefghij",
+		"query":    "a b/c",
+		"empty":    "",
+	}
+
+	tests := map[string]string{
+		"{{.word | upper}}":                            "BAHAY",
+		"{{lower \"BAHAY\"}}":                          "bahay",
+		"{{join \"; \" .meanings}}":                    "house; home",
+		"{{range .meanings}}[{{.}}]{{end}}":            "[house][home]",
+		"{{.long | truncate 5}}":                       "abcd…",
+		"{{.word | truncate 5}}":                       "bahay",
+		"{{urlescape .query}}":                         "a%20b%2Fc",
+		"{{.missing | default \"none\"}}":              "none",
+		"{{.empty | default \"none\"}}":                "none",
+		"{{if .missing}}yes{{else}}no{{end}}":          "no",
+		"{{.missing}}":                                 "",
+		"{{index .Captures 0}}":                        "",
+		"{{with .word}}{{.}}{{end}} {{len .meanings}}": "bahay 2",
+	}
+
+	for text, expect := range tests {
+		data["Captures"] = []interface{}{""}
+		got, err := executeTemplate(text, data)
+		if err != nil || got != expect {
+			t.Errorf("%s: expected \"%s\", got \"%s\" (%v)", text, expect, got, err)
+		}
+	}
+}
+
+func TestExecuteTemplateMissing(t *testing.T) {
+	data := map[string]interface{}{
+		"meanings": []interface{}{"house", "home"},
+		"null":     nil,
+		"literal":  "<no value>",
+	}
+
+	tests := map[string]string{
+		"{{.null}}": "",
+		"{{range .meanings}}[{{$.missing}}]{{end}}":           "[][]",
+		`{{define "d"}}{{.missing}}{{end}}{{template "d" .}}`: "",
+		"<no value> {{.literal}}":                             "<no value> <no value>",
+	}
+
+	for text, expect := range tests {
+		got, err := executeTemplate(text, data)
+		if err != nil || got != expect {
+			t.Errorf("%s: expected \"%s\", got \"%s\" (%v)", text, expect, got, err)
+		}
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	data := templateData([]string{"first", "", "third"}, []interface{}{"a", "b"})
+	if data["first"] != "a" || len(data) != 2 {
+		t.Errorf("Unexpected data: %v", data)
+	}
+
+	data = parameterData([]Parameter{{Name: "word"}}, []interface{}{"bahay"})
+	if data["word"] != "bahay" || len(data["Parameters"].([]interface{})) != 1 {
+		t.Errorf("Unexpected data: %v", data)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	if errs := validateTemplate("Template", "%s"); len(errs) != 0 {
+		t.Errorf("A %%s template isn't a text/template template")
+	}
+
+	if errs := validateTemplate("Template", "{{.word"); len(errs) != 1 {
+		t.Errorf("An invalid template should be a problem")
+	}
+
+	if errs := validateTemplate("Template", "{{unknown .word}}"); len(errs) != 1 {
+		t.Errorf("An unknown function should be a problem")
+	}
+
+	if errs := validateTemplate("Template", "%s }} {{"); len(errs) != 1 {
+		t.Errorf("A \"{{\" that doesn't start an action should be a problem")
+	}
+}
+
+func TestIsTextTemplate(t *testing.T) {
+	tests := map[string]bool{
+		"%s":                false,
+		"{{.word}}":         true,
+		"{{.word":           false,
+		"}} {{":             false,
+		"{{\"{{\"}}":        true,
+		"a {{\n.word\n}} b": true,
+	}
+
+	for text, expect := range tests {
+		if got := isTextTemplate(text); got != expect {
+			t.Errorf("%q: expected %v, got %v", text, expect, got)
+		}
+	}
+}
+
+func TestParseTemplateOnce(t *testing.T) {
+	first, err := parseTemplate("{{.word}} once")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second, _ := parseTemplate("{{.word}} once"); first != second {
+		t.Errorf("A template should only be parsed once")
+	}
+
+	if got, _ := executeTemplate(`{{"{{"}}.word}}`, nil); got != "{{.word}}" {
+		t.Errorf("Unexpected text: %s", got)
+	}
+}
+
+func TestGoQueryScraperTemplate(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := GoQueryScraperConfig{
+		Trigger:    "gq",
+		Parameters: []Parameter{{Type: "string", Name: "page"}},
+		URL:        "{{.page | urlescape}}",
+		TitleSelector: SelectorCapture{
+			Template:  "{{.heading | upper}}",
+			Selectors: []string{"h1"},
+			Names:     []string{"heading"},
+		},
+		ReplySelector: SelectorCapture{
+			Template:  "{{if .missing}}{{.missing}}{{else}}{{index .Captures 0}}{{end}}",
+			Selectors: []string{"h2", "h3"},
+			Names:     []string{"", "missing"},
+		},
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	scraper, err := config.CommandWithHTMLGetter(htmlTestPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scraper.Exec(testConversation, testSender, []interface{}{"usual"}, nil, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+	if resultMessage.Title != "HEADING ONE" || resultMessage.Description != "Heading Two" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
+
+func TestJSONTemplate(t *testing.T) {
+	config := JSONGetterConfig{
+		Grouped: true,
+		Message: JSONCapture{
+			Title: FieldCapture{
+				Template:  "{{.word}}{{if .JSON.common}} (common){{end}}",
+				Selectors: []string{"word"},
+				Names:     []string{"word"},
+			},
+			Body: FieldCapture{
+				Template:  "{{range $i, $m := .meanings}}{{if $i}}, {{end}}{{$m.text | truncate 4}}{{end}}",
+				Selectors: []string{"meanings"},
+				Names:     []string{"meanings"},
+			},
+		},
+	}
+
+	messages := config.MessagesFromJSON(decodeJSON(t, `{
+		"word": "bahay",
+		"common": true,
+		"meanings": [{"text": "house"}, {"text": "home"}]
+	}`))
+
+	if len(messages) != 1 || messages[0].Title != "bahay (common)" || messages[0].Description != "hou…, home" {
+		t.Errorf("Unexpected messages: %v", messages)
+	}
+}
+
+func TestJSONTemplateURL(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := JSONGetterConfig{
+		Parameters: []Parameter{{Type: "string", Name: "word"}},
+		URL:        "https://example.com/?q={{urlescape .word}}",
+		Message:    JSONCapture{Body: FieldCapture{Template: "%s", Selectors: []string{"URL"}}},
+	}
+
+	command, _ := config.Command(jsonURLReturn)
+	command.Exec(testConversation, testSender, []interface{}{"a%sb c"}, nil, demoSender.SendMessage)
+
+	resultMessage, _ := demoSender.PopMessage()
+	if resultMessage.Description != "https://example.com/?q=a%25sb%20c" {
+		t.Errorf("Unexpected URL: %s", resultMessage.Description)
+	}
+}
+
+func TestRegexpScraperTemplate(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := RegexpScraperConfig{
+		Trigger:       "rx",
+		TitleTemplate: "{{join \" & \" .Captures}}",
+		TitleCapture:  "<h1>(.*)</h1>",
+		ReplyCapture:  "<h2>(.*)</h2>",
+		ReplyTemplate: "{{range .Matches}}- {{index . 0}}\n{{end}}",
+		URL:           "usual",
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	scraper, err := config.CommandWithHTMLGetter(htmlTestPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scraper.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+	resultMessage, _ := demoSender.PopMessage()
+	if resultMessage.Title != "Heading One & Last Heading One" {
+		t.Errorf("Unexpected title: %s", resultMessage.Title)
+	}
+
+	if resultMessage.Description != "- Heading Two\n- 2nd Heading Two\n" {
+		t.Errorf("Unexpected reply: %s", resultMessage.Description)
+	}
+
+	config.ReplyTemplate = "{{range .Matches}"
+	if config.Validate() == nil {
+		t.Errorf("An invalid ReplyTemplate should be a problem")
+	}
+}
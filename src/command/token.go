@@ -0,0 +1,192 @@
+package command
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+)
+
+// tokenTypes are the types of tokens that a TokenMaker can make, other than no token.
+var tokenTypes = []string{"MD5", "SHA1", "SHA256", "HMAC-SHA256", "Timestamp", "Nonce"}
+
+// tokenPlacements are where a TokenMaker can put a token.
+var tokenPlacements = []string{"URL", "Header", "Query"}
+
+// tokenNow returns the current time, used for timestamps. It can be replaced for tests.
+var tokenNow = time.Now
+
+// tokenNonce returns a random value that is only used once. It can be replaced for tests.
+var tokenNonce = func() string {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return strconv.FormatInt(tokenNow().UnixNano(), 16)
+	}
+	return hex.EncodeToString(nonce)
+}
+
+// A TokenMaker is useful for creating a token that may be part of an API request.
+//
+// Prefix, Postfix and Suffix can be text/template templates (see templateDelimiter), where
+// .Timestamp is the current Unix time in seconds and .Nonce is a random value. Both are the
+// same for every template of a token, so a timestamp can be both hashed and sent.
+type TokenMaker struct {
+	Type      string // Can be MD5, SHA1, SHA256, HMAC-SHA256, Timestamp or Nonce. If empty, only Suffix is used.
+	Prefix    string // When calculating a token, what should be prepended
+	Postfix   string // When calculating a token, what should be appended
+	Key       string // The secret key used by HMAC-SHA256.
+	Size      int    // Take the first 'Size' characters from the result. If 0, the whole result is used.
+	Suffix    string // String to append after the token
+	Placement string // Where the token is put: "URL" (appended to it, the default), "Header" or "Query".
+	Name      string // The name of the header or query parameter, when Placement is "Header" or "Query".
+}
+
+// tokenValues are the dynamic values that can be used by the templates of a TokenMaker.
+type tokenValues struct {
+	Timestamp string
+	Nonce     string
+}
+
+// fill fills out text if it is a text/template template, otherwise text is returned.
+func (t TokenMaker) fill(text string, values tokenValues) (string, error) {
+	if !isTextTemplate(text) {
+		return text, nil
+	}
+	return executeTemplate(text, values)
+}
+
+// MakeToken will make a token from this token maker.
+// Prefix is prepended and Postfix is appended to the input, which is then hashed according to
+// Type, and the first Size characters of the hex encoded result are followed by Suffix.
+// A "Timestamp" or "Nonce" token is that value, rather than a hash.
+// An error is returned if Type is unknown, or a template can't be filled out.
+func (t TokenMaker) MakeToken(input string) (string, error) {
+	values := tokenValues{
+		Timestamp: strconv.FormatInt(tokenNow().Unix(), 10),
+		Nonce:     tokenNonce(),
+	}
+
+	parts := make([]string, 3)
+	for i, text := range []string{t.Prefix, t.Postfix, t.Suffix} {
+		filled, err := t.fill(text, values)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = filled
+	}
+
+	prefix, postfix, suffix := parts[0], parts[1], parts[2]
+	fullString := []byte(prefix + input + postfix)
+
+	var sum hash.Hash
+	token := ""
+	switch t.Type {
+	case "":
+		return suffix, nil
+	case "MD5":
+		sum = md5.New()
+	case "SHA1":
+		sum = sha1.New()
+	case "SHA256":
+		sum = sha256.New()
+	case "HMAC-SHA256":
+		sum = hmac.New(sha256.New, []byte(t.Key))
+	case "Timestamp":
+		token = values.Timestamp
+	case "Nonce":
+		token = values.Nonce
+	default:
+		return "", fmt.Errorf("unknown Token.Type \"%s\"", t.Type)
+	}
+
+	if sum != nil {
+		sum.Write(fullString)
+		token = hex.EncodeToString(sum.Sum(nil))
+	}
+
+	if t.Size > 0 && t.Size < len(token) {
+		token = token[:t.Size]
+	}
+	return token + suffix, nil
+}
+
+// place puts token in request according to Placement. Nothing is done if token is empty.
+func (t TokenMaker) place(request *utils.Request, token string) error {
+	if token == "" {
+		return nil
+	}
+
+	switch t.Placement {
+	case "", "URL":
+		request.URL += token
+	case "Header":
+		request.Header.Set(t.Name, token)
+	case "Query":
+		parsed, err := url.Parse(request.URL)
+		if err != nil {
+			return err
+		}
+
+		query := parsed.Query()
+		query.Set(t.Name, token)
+		parsed.RawQuery = query.Encode()
+		request.URL = parsed.String()
+	default:
+		return fmt.Errorf("unknown Token.Placement \"%s\"", t.Placement)
+	}
+	return nil
+}
+
+// validate returns every problem found with the type, placement and templates.
+func (t TokenMaker) validate() Errors {
+	errs := Errors{}
+	if t.Type != "" && !containsString(tokenTypes, t.Type) {
+		errs = append(errs, fmt.Errorf(
+			"unknown Token.Type \"%s\", expected one of: %s",
+			t.Type,
+			strings.Join(tokenTypes, ", "),
+		))
+	}
+
+	if t.Type == "HMAC-SHA256" && t.Key == "" {
+		errs = append(errs, fmt.Errorf("a Token.Key is needed for HMAC-SHA256"))
+	}
+
+	if t.Size < 0 {
+		errs = append(errs, fmt.Errorf("the Token.Size can't be negative"))
+	}
+
+	if t.Placement != "" && !containsString(tokenPlacements, t.Placement) {
+		errs = append(errs, fmt.Errorf(
+			"unknown Token.Placement \"%s\", expected one of: %s",
+			t.Placement,
+			strings.Join(tokenPlacements, ", "),
+		))
+	} else if (t.Placement == "Header" || t.Placement == "Query") && t.Name == "" {
+		errs = append(errs, fmt.Errorf("a Token.Name is needed to put the token in a %s", t.Placement))
+	}
+
+	errs = append(errs, validateTemplate("Token.Prefix", t.Prefix)...)
+	errs = append(errs, validateTemplate("Token.Postfix", t.Postfix)...)
+	return append(errs, validateTemplate("Token.Suffix", t.Suffix)...)
+}
+
+// containsString returns true if values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package command
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+)
+
+// fixedTokenValues makes tokens use a fixed timestamp and nonce, until the returned function is called.
+func fixedTokenValues() func() {
+	now, nonce := tokenNow, tokenNonce
+	tokenNow = func() time.Time { return time.Unix(1700000000, 0) }
+	tokenNonce = func() string { return "abc123" }
+	return func() { tokenNow, tokenNonce = now, nonce }
+}
+
+func TestMakeToken(t *testing.T) {
+	defer fixedTokenValues()()
+
+	tests := []struct {
+		token    TokenMaker
+		expected string
+	}{
+		{TokenMaker{}, ""},
+		{TokenMaker{Suffix: "&a=1"}, "&a=1"},
+		{TokenMaker{Type: "MD5", Prefix: "Y", Postfix: "X", Size: 6}, "2d1105"},
+		{TokenMaker{Type: "SHA1", Prefix: "Y", Postfix: "X"}, "03a51abf37625359706ac58fdd0ce3355da08de2"},
+		{TokenMaker{Type: "SHA256", Prefix: "Y", Postfix: "X", Size: 8}, "bd59ae7c"},
+		{TokenMaker{Type: "HMAC-SHA256", Prefix: "Y", Postfix: "X", Key: "secret"}, "4468cef4b2b54f8f7155c5092042f2a158551e31b706d1002736f4ac28ac5fc7"},
+		{TokenMaker{Type: "Timestamp", Suffix: "&n={{.Nonce}}"}, "1700000000&n=abc123"},
+		{TokenMaker{Type: "Nonce", Size: 3}, "abc"},
+	}
+
+	for _, test := range tests {
+		token, err := test.token.MakeToken("Hello World")
+		if err != nil || token != test.expected {
+			t.Errorf("Token of %v was %q (%v), expected %q", test.token, token, err, test.expected)
+		}
+	}
+}
+
+func TestTokenWithTimestamp(t *testing.T) {
+	defer fixedTokenValues()()
+
+	maker := TokenMaker{
+		Type:    "MD5",
+		Prefix:  "{{.Timestamp}}",
+		Postfix: "pub",
+		Suffix:  "&ts={{.Timestamp}}",
+	}
+
+	token, err := maker.MakeToken("word")
+	if err != nil || token != "1100de79f6bcf14ffaf0d3b49b4d463b&ts=1700000000" {
+		t.Errorf("Unexpected token: %q (%v)", token, err)
+	}
+}
+
+func TestUnknownTokenType(t *testing.T) {
+	maker := TokenMaker{Type: "CRC32"}
+	if _, err := maker.MakeToken("word"); err == nil {
+		t.Errorf("An unknown type should be an error")
+	}
+
+	if errs := maker.validate(); len(errs) != 1 {
+		t.Errorf("Expected 1 problem, got: %v", errs)
+	}
+}
+
+func TestPlaceToken(t *testing.T) {
+	tests := []struct {
+		token          TokenMaker
+		expectedURL    string
+		expectedHeader string
+	}{
+		{TokenMaker{}, "https://example.com/a?b=c&sig=1", ""},
+		{TokenMaker{Placement: "Header", Name: "X-Signature"}, "https://example.com/a?b=c", "&sig=1"},
+		{TokenMaker{Placement: "Query", Name: "sig"}, "https://example.com/a?b=c&sig=%26sig%3D1", ""},
+	}
+
+	for _, test := range tests {
+		request := utils.Request{URL: "https://example.com/a?b=c", Header: http.Header{}}
+		if err := test.token.place(&request, "&sig=1"); err != nil {
+			t.Errorf("Couldn't place token: %s", err)
+		}
+
+		if request.URL != test.expectedURL || request.Header.Get("X-Signature") != test.expectedHeader {
+			t.Errorf("Token was placed incorrectly by %v: %v", test.token, request)
+		}
+	}
+}
+
+func TestInvalidToken(t *testing.T) {
+	tests := []TokenMaker{
+		{Type: "HMAC-SHA256"},
+		{Type: "MD5", Size: -1},
+		{Type: "MD5", Placement: "Body"},
+		{Type: "MD5", Placement: "Header"},
+		{Type: "MD5", Prefix: "{{.Timestamp"},
+	}
+
+	for _, test := range tests {
+		if errs := test.validate(); len(errs) != 1 {
+			t.Errorf("Expected 1 problem with %v, got: %v", test, errs)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParameterTypes are the types that a Parameter can have.
+var ParameterTypes = []string{"string", "int", "bool", "user", "role"}
+
+// Errors combines several errors into one, such as every problem found with a config.
+type Errors []error
+
+// Error returns every error, separated by new lines.
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// errorsOrNil returns errs as an error, or nil if there are no errors.
+func errorsOrNil(errs Errors) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// AppendError appends err to errs, flattening err if it is also Errors.
+func AppendError(errs Errors, err error) Errors {
+	if err == nil {
+		return errs
+	}
+
+	if more, ok := err.(Errors); ok {
+		return append(errs, more...)
+	}
+	return append(errs, err)
+}
+
+// validateTrigger returns a problem if a trigger can't be used.
+func validateTrigger(trigger string) Errors {
+	if trigger == "" {
+		return Errors{fmt.Errorf("empty Trigger")}
+	}
+
+	if strings.ContainsAny(trigger, " \t\n") {
+		return Errors{fmt.Errorf("whitespace in Trigger \"%s\"", trigger)}
+	}
+	return nil
+}
+
+// validateParameters returns a problem for each parameter with an unknown type.
+func validateParameters(parameters []Parameter) Errors {
+	errs := Errors{}
+	for i, parameter := range parameters {
+		known := false
+		for _, parameterType := range ParameterTypes {
+			known = known || parameter.Type == parameterType
+		}
+
+		if !known {
+			errs = append(errs, fmt.Errorf(
+				"unknown type \"%s\" for Parameters[%d], expected one of: %s",
+				parameter.Type,
+				i,
+				strings.Join(ParameterTypes, ", "),
+			))
+		}
+	}
+	return errs
+}
+
+// validateURLSubstitutions returns a problem if url can't be filled out by parameters.
+// If exact is true, every parameter must be used by url.
+func validateURLSubstitutions(url string, parameters []Parameter, exact bool) Errors {
+	if isTextTemplate(url) {
+		return validateTemplate("URL", url)
+	}
+
+	substitutions := strings.Count(url, "%s")
+	if substitutions > len(parameters) || (exact && substitutions != len(parameters)) {
+		return Errors{fmt.Errorf(
+			"the URL \"%s\" has %d \"%%s\" but there are %d Parameters",
+			url,
+			substitutions,
+			len(parameters),
+		)}
+	}
+	return nil
+}
+
+// Validate returns a problem if a rate limit is used without an ID.
+func (r RateLimitConfig) Validate() error {
+	if (r.TimesPerInterval != 0 || r.SecondsPerInterval != 0) && r.ID == "" {
+		return Errors{fmt.Errorf("missing ID for RateLimit")}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package command
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidGoQueryScraperConfig(t *testing.T) {
+	config := GoQueryScraperConfig{
+		Trigger:    "gq",
+		Parameters: []Parameter{{Type: "string"}},
+		URL:        "https://example.com/%s",
+		ReplySelector: SelectorCapture{
+			Template:       "%s %s",
+			Selectors:      []string{"h1", "h2"},
+			HandleMultiple: "Random",
+		},
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Config should be valid: %s", err)
+	}
+}
+
+func TestInvalidGoQueryScraperConfig(t *testing.T) {
+	config := GoQueryScraperConfig{
+		Trigger:    "",
+		Parameters: []Parameter{{Type: "word"}},
+		URL:        "https://example.com/%s/%s",
+		TitleSelector: SelectorCapture{
+			Template:  "%s",
+			Selectors: []string{"h1", "h2"},
+		},
+		Fields: []GoQueryFieldCapture{
+			{Description: SelectorCapture{HandleMultiple: "Second"}},
+		},
+	}
+
+	err := config.Validate()
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 5 {
+		t.Fatalf("Expected 5 problems, got: %v", err)
+	}
+
+	for _, expect := range []string{"Trigger", "word", "URL", "TitleSelector", "Fields[0].Description"} {
+		if !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected a problem mentioning %s", expect)
+		}
+	}
+}
+
+func TestGoQueryScraperURLMustUseParameters(t *testing.T) {
+	config := GoQueryScraperConfig{
+		Trigger:    "gq",
+		Parameters: []Parameter{{Type: "string"}},
+		URL:        "https://example.com/",
+	}
+
+	if config.Validate() == nil {
+		t.Errorf("An unused parameter breaks the URL")
+	}
+}
+
+func TestJSONGetterConfigValidate(t *testing.T) {
+	config := JSONGetterConfig{
+		Trigger:    "j",
+		Parameters: []Parameter{{Type: "string"}, {Type: "int"}},
+		URL:        "https://example.com/%s",
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("JSON URLs don't need to use every parameter: %s", err)
+	}
+
+	config.URL = "https://example.com/%s/%s/%s"
+	config.RateLimit = RateLimitConfig{TimesPerInterval: 1, SecondsPerInterval: 1}
+	err := config.Validate()
+	if errs, ok := err.(Errors); !ok || len(errs) != 2 {
+		t.Errorf("Expected problems with the URL and the rate limit, got: %v", err)
+	}
+}
+
+func TestRegexpScraperConfigValidate(t *testing.T) {
+	config := RegexpScraperConfig{
+		Trigger:      "has space",
+		URL:          "https://example.com/",
+		ReplyCapture: "(",
+		TitleCapture: "[",
+	}
+
+	err := config.Validate()
+	if errs, ok := err.(Errors); !ok || len(errs) != 3 {
+		t.Errorf("Expected 3 problems, got: %v", err)
+	}
+}
+
+func TestRegexpScraperBadRegexpDoesntPanic(t *testing.T) {
+	config := RegexpScraperConfig{Trigger: "rx", ReplyCapture: "("}
+	if _, err := config.Command(); err == nil {
+		t.Errorf("An invalid ReplyCapture should be an error")
+	}
+
+	config = RegexpScraperConfig{Trigger: "rx", TitleCapture: "("}
+	if _, err := config.Command(); err == nil {
+		t.Errorf("An invalid TitleCapture should be an error")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if errorsOrNil(Errors{}) != nil {
+		t.Errorf("No errors should be nil")
+	}
+
+	errs := AppendError(Errors{}, Errors{validateTrigger("")[0], validateTrigger("a b")[0]})
+	errs = AppendError(errs, nil)
+	if len(errs) != 2 {
+		t.Errorf("Errors should be flattened, and nil should be ignored")
+	}
+
+	if errs.Error() != "empty Trigger\nwhitespace in Trigger \"a b\"" {
+		t.Errorf("Unexpected message: %s", errs.Error())
+	}
+}
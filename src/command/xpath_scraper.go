@@ -0,0 +1,392 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// xmlEncodingDeclaration matches the encoding of an XML declaration, such as
+// `<?xml version="1.0" encoding="ISO-8859-1"?>`.
+var xmlEncodingDeclaration = regexp.MustCompile(`^(\s*<\?xml[^>]*\sencoding\s*=\s*["'])[^"']*`)
+
+// XPathScraperConfig can be turned into a scraper of XML documents that uses XPath.
+type XPathScraperConfig struct {
+	Trigger       string        // Word which triggers this command to activate.
+	Parameters    []Parameter   // How to capture words.
+	TitleSelector XPathCapture  // The output message's title.
+	ErrorURL      string        // A url to show only when there is an error.
+	URL           string        // A url to scrape from, can contain "%s" which are replaced with captures. Can instead be a text/template template.
+	URLSuffix     string        // When adding a URL to a message, this string is appended. This is useful for including referral links.
+	Request       RequestConfig // Optionally, how to request the URL (such as the method and headers).
+	Cache         CacheConfig   // Optionally, how long to cache responses for.
+	ReplySelector XPathCapture  // The output message's body text.
+	Fields        []XPathFieldCapture
+	Namespaces    map[string]string // Prefixes that XPath expressions can use, and the namespace URI of each.
+	Help          string            // Help message to display.
+	HelpInput     string            // Help message to display for input following command.
+	HideURL       bool              // When true, a result returns no URL. Use with caution, attribution is often required.
+	ReplyInThread bool              // See Command.ReplyInThread.
+}
+
+// XPathCapture is like a SelectorCapture, but its Selectors are XPath expressions, which can
+// select elements, attributes or text. Extract can be "Text" or an attribute, but not "Markdown".
+type XPathCapture SelectorCapture
+
+// XPathFieldCapture is like a GoQueryFieldCapture, but uses XPath expressions. A Container's
+// Title and Description are relative to it, such as "./title".
+type XPathFieldCapture struct {
+	Title          XPathCapture
+	Description    XPathCapture
+	HandleMultiple string // If "All", every match becomes its own field. Otherwise, there's at most one field.
+	Container      string // With "All", an expression for each match, which Title and Description select within. If empty, matches are aligned by index.
+	Max            int    // With "All", the most fields to make, up to service.MaxFields. If 0, service.MaxFields is used.
+	Numbered       bool   // With "All", when true each title is numbered, such as "1. Title".
+}
+
+// xpathExprs maps each XPath expression of a config to the expression compiled, so that
+// expressions are only compiled once.
+type xpathExprs map[string]*xpath.Expr
+
+// compile compiles expression using namespaces, unless it is already compiled.
+func (e xpathExprs) compile(expression string, namespaces map[string]string) error {
+	if _, ok := e[expression]; ok {
+		return nil
+	}
+
+	expr, err := xpath.CompileWithNS(expression, namespaces)
+	if err != nil {
+		return err
+	}
+	e[expression] = expr
+	return nil
+}
+
+// parseXML returns the root of the XML document read from reader. As responses are already
+// decoded to UTF-8, the encoding that the document declares is ignored.
+func parseXML(reader io.Reader) (*xmlquery.Node, error) {
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	contents = xmlEncodingDeclaration.ReplaceAll(contents, []byte("${1}UTF-8"))
+	return xmlquery.Parse(strings.NewReader(string(contents)))
+}
+
+// toString matches all selectors within root and fills out the template.
+// Then using HandleMultiple decide which to use. Relative URLs are resolved against base.
+func (x XPathCapture) toString(root *xmlquery.Node, base *url.URL, exprs xpathExprs) (string, error) {
+	if SelectorCapture(x).static() {
+		return x.Template, nil
+	}
+
+	allCaptures, length, err := x.find(root, exprs)
+	if err != nil {
+		return "", err
+	}
+	return x.fill(allCaptures, SelectorCapture(x).pick(length), base)
+}
+
+// find returns the matches of each selector within root, and the fewest matches of any selector.
+// If the template is static, there's no limit to the matches. Each selector must be in exprs.
+func (x XPathCapture) find(root *xmlquery.Node, exprs xpathExprs) ([][]*xmlquery.Node, int, error) {
+	if SelectorCapture(x).static() {
+		return nil, math.MaxInt32, nil
+	}
+
+	length := math.MaxInt32
+	allCaptures := make([][]*xmlquery.Node, len(x.Selectors))
+	for i, selector := range x.Selectors {
+		expr, ok := exprs[selector]
+		if !ok {
+			return nil, 0, fmt.Errorf("the XPath expression \"%s\" wasn't compiled", selector)
+		}
+
+		allCaptures[i] = xmlquery.QuerySelectorAll(root, expr)
+		if len(allCaptures[i]) < length {
+			length = len(allCaptures[i])
+		}
+	}
+	return allCaptures, length, nil
+}
+
+// fill fills out the template using the index-th match of each selector.
+func (x XPathCapture) fill(allCaptures [][]*xmlquery.Node, index int, base *url.URL) (string, error) {
+	if SelectorCapture(x).static() {
+		return x.Template, nil
+	}
+
+	values := make([]string, len(x.Selectors))
+	for i, nodes := range allCaptures {
+		if index < len(nodes) {
+			values[i] = x.extract(i, nodes[index], base)
+		}
+	}
+	return SelectorCapture(x).format(values)
+}
+
+// extract returns the text of a node matched by the i-th selector, as configured by Extract.
+func (x XPathCapture) extract(i int, node *xmlquery.Node, base *url.URL) string {
+	if i < len(x.Extract) && strings.HasPrefix(x.Extract[i], "@") {
+		attribute := x.Extract[i][1:]
+		val := strings.TrimSpace(node.SelectAttr(attribute))
+		if urlAttributes[strings.ToLower(attribute)] && val != "" {
+			return resolveURL(base, val)
+		}
+		return val
+	}
+	return strings.TrimSpace(node.InnerText())
+}
+
+// validate returns a problem if the template can't be filled out by the selectors, a selector
+// isn't a valid XPath expression, or HandleMultiple or Extract is unknown. Valid selectors are
+// compiled into exprs. name is used to identify this in problems.
+func (x XPathCapture) validate(name string, namespaces map[string]string, exprs xpathExprs) Errors {
+	errs := SelectorCapture(x).validate(name)
+	for i, extract := range x.Extract {
+		if strings.EqualFold(extract, "Markdown") {
+			errs = append(errs, fmt.Errorf("the Extract[%d] of %s can't be Markdown, as it selects XML", i, name))
+		}
+	}
+
+	for i, selector := range x.Selectors {
+		if err := exprs.compile(selector, namespaces); err != nil {
+			errs = append(errs, fmt.Errorf("the Selectors[%d] of %s isn't a valid XPath expression: %w", i, name, err))
+		}
+	}
+	return errs
+}
+
+// validate returns every problem found with the expressions, HandleMultiple and Max.
+// Valid expressions are compiled into exprs. name is used to identify this in problems.
+func (f XPathFieldCapture) validate(name string, namespaces map[string]string, exprs xpathExprs) Errors {
+	errs := f.Title.validate(name+".Title", namespaces, exprs)
+	errs = append(errs, f.Description.validate(name+".Description", namespaces, exprs)...)
+	if f.HandleMultiple != "" && f.HandleMultiple != "All" {
+		errs = append(errs, fmt.Errorf(
+			"unknown HandleMultiple \"%s\" for %s, expected All or nothing",
+			f.HandleMultiple,
+			name,
+		))
+	}
+
+	if f.Container != "" {
+		if err := exprs.compile(f.Container, namespaces); err != nil {
+			errs = append(errs, fmt.Errorf("the Container of %s isn't a valid XPath expression: %w", name, err))
+		}
+	}
+
+	return append(errs, validateMax(name+".Max", f.Max)...)
+}
+
+// fields returns the fields captured from root. Unless HandleMultiple is "All", there is at
+// most one field. Fields with an empty title or value are skipped.
+func (f XPathFieldCapture) fields(root *xmlquery.Node, base *url.URL, exprs xpathExprs) []service.MessageField {
+	fields := make([]service.MessageField, 0)
+	add := fieldAdder(&fields, f.Numbered && f.HandleMultiple == "All", f.Max)
+
+	if f.HandleMultiple != "All" {
+		title, err1 := f.Title.toString(root, base, exprs)
+		value, err2 := f.Description.toString(root, base, exprs)
+		if err1 == nil && err2 == nil {
+			add(title, value)
+		}
+		return fields
+	}
+
+	if f.Container != "" {
+		expr, ok := exprs[f.Container]
+		if !ok {
+			return fields
+		}
+
+		for _, container := range xmlquery.QuerySelectorAll(root, expr) {
+			title, err1 := f.Title.toString(container, base, exprs)
+			value, err2 := f.Description.toString(container, base, exprs)
+			if err1 == nil && err2 == nil && !add(title, value) {
+				break
+			}
+		}
+		return fields
+	}
+
+	titles, titleCount, err1 := f.Title.find(root, exprs)
+	values, valueCount, err2 := f.Description.find(root, exprs)
+	if err1 != nil || err2 != nil {
+		return fields
+	}
+
+	count := titleCount
+	if valueCount < count {
+		count = valueCount
+	}
+
+	if count == math.MaxInt32 {
+		count = 1
+	}
+
+	for i := 0; i < count; i++ {
+		title, err1 := f.Title.fill(titles, i, base)
+		value, err2 := f.Description.fill(values, i, base)
+		if err1 == nil && err2 == nil && !add(title, value) {
+			break
+		}
+	}
+	return fields
+}
+
+// Validate returns every problem found with this config.
+func (x XPathScraperConfig) Validate() error {
+	_, errs := x.compile()
+	return errorsOrNil(errs)
+}
+
+// compile returns every XPath expression of this config compiled, and every problem found
+// with this config.
+func (x XPathScraperConfig) compile() (xpathExprs, Errors) {
+	exprs := make(xpathExprs)
+	errs := validateTrigger(x.Trigger)
+	errs = append(errs, validateParameters(x.Parameters)...)
+	errs = append(errs, validateURLSubstitutions(x.URL, x.Parameters, false)...)
+	errs = append(errs, x.Request.validate()...)
+	errs = append(errs, x.Cache.validate()...)
+	errs = append(errs, x.TitleSelector.validate("TitleSelector", x.Namespaces, exprs)...)
+	errs = append(errs, x.ReplySelector.validate("ReplySelector", x.Namespaces, exprs)...)
+	for i, field := range x.Fields {
+		errs = append(errs, field.validate(fmt.Sprintf("Fields[%d]", i), x.Namespaces, exprs)...)
+	}
+	return exprs, errs
+}
+
+// Command returns an XPath scraper Command from a config.
+func (x XPathScraperConfig) Command() (Command, error) {
+	return x.CommandWithHTMLRequester(HTMLGetWithRequest)
+}
+
+// CommandWithHTMLRequester makes an XPath scraper Command from a config, retrieving XML documents
+// using HTMLRequester. Every XPath expression is compiled once, when the command is made.
+func (x XPathScraperConfig) CommandWithHTMLRequester(htmlRequester HTMLRequester) (Command, error) {
+	exprs, errs := x.compile()
+	if len(errs) > 0 {
+		return Command{}, errs
+	}
+
+	htmlRequester = DefaultCache.HTMLRequester(x.Trigger, x.Cache, DefaultBreaker.HTMLRequester(DefaultFetcher.HTMLRequester(htmlRequester)))
+	curry := func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+		x.onMessage(sender, user, msg, storage, sink, htmlRequester, exprs)
+	}
+
+	return Command{
+		Trigger:       x.Trigger,
+		Parameters:    x.Parameters,
+		Exec:          curry,
+		Help:          x.Help,
+		HelpInput:     x.HelpInput,
+		ReplyInThread: x.ReplyInThread,
+	}, nil
+}
+
+// onMessage processes the request, and sends out messages.
+func (x XPathScraperConfig) onMessage(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message), htmlRequester HTMLRequester, exprs xpathExprs) {
+	msgURL, err := buildURL(x.URL, x.Parameters, msg)
+	if err != nil {
+		sink(sender, service.Message{Description: urlErrorMessage, Failed: true})
+		return
+	}
+
+	request, err := x.Request.Request(msgURL, x.Parameters, msg)
+	if err != nil {
+		sink(sender, service.Message{Description: buildRequestErrorMessage, Failed: true})
+		return
+	}
+
+	redirect, reader, err := htmlRequester(request)
+	if err != nil {
+		sink(sender, service.Message{Title: "Error", Description: requestErrorMessage(err), URL: msgURL, Failed: true})
+		return
+	}
+	defer reader.Close()
+
+	root, err := parseXML(reader)
+	if err != nil {
+		sink(sender, service.Message{
+			Title:       msgURL,
+			Description: processErrorMessage,
+			Failed:      true,
+			URL:         x.ErrorURL,
+		})
+		return
+	}
+
+	pageURL := redirect
+	if pageURL == "" {
+		pageURL = msgURL
+	}
+	base, _ := url.Parse(pageURL)
+
+	if strings.TrimSpace(root.InnerText()) == "" && len(root.SelectElements("*")) == 0 {
+		captures := []string{}
+		for _, item := range msg {
+			captures = append(captures, item.(string))
+		}
+
+		sink(sender, service.Message{
+			Title:       "Error",
+			Description: fmt.Sprintf("No result was found for \"%s\"", strings.Join(captures, " ")),
+			Failed:      true,
+			URL:         x.ErrorURL,
+		})
+		return
+	}
+
+	fields := make([]service.MessageField, 0)
+	title, err1 := x.TitleSelector.toString(root, base, exprs)
+	value, err2 := x.ReplySelector.toString(root, base, exprs)
+	if err1 == nil && err2 == nil && title != "" && value != "" {
+		if x.HideURL {
+			redirect = ""
+		}
+
+		fields = append(fields, service.MessageField{
+			Field: title,
+			Value: value,
+			URL:   redirect + x.URLSuffix,
+		})
+	}
+
+	for _, field := range x.Fields {
+		fields = append(fields, field.fields(root, base, exprs)...)
+	}
+
+	failed := len(fields) == 0
+	if failed {
+		fields = append(fields, service.MessageField{
+			Field: "Error",
+			Value: "No result was found",
+			URL:   x.ErrorURL,
+		})
+	}
+
+	replyMsg := service.Message{
+		Title:       fields[0].Field,
+		Description: fields[0].Value,
+		URL:         fields[0].URL,
+		Failed:      failed,
+	}
+
+	if len(fields) > 1 {
+		replyMsg.Fields = fields[1:]
+	}
+
+	sink(sender, replyMsg)
+}
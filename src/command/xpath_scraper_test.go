@@ -0,0 +1,172 @@
+package command
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+	"github.com/BKrajancic/boby/m/v2/src/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+const dictionaryXML = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<d:dictionary xmlns:d="urn:example:dictionary" xmlns:x="http://www.w3.org/1999/xlink">
+	<d:entry word="bahay">
+		<d:headword>bahay</d:headword>
+		<d:sense pos="noun"><d:gloss>house</d:gloss></d:sense>
+		<d:sense pos="noun"><d:gloss>home</d:gloss></d:sense>
+		<d:sense pos="verb"><d:gloss>to nest</d:gloss></d:sense>
+		<d:audio x:href="audio/bahay.mp3" href="audio/bahay.ogg"/>
+	</d:entry>
+</d:dictionary>`
+
+// xmlRequester returns an HTMLRequester that responds with document, remembering the request made.
+func xmlRequester(document string, received *utils.Request) HTMLRequester {
+	return func(request utils.Request) (string, io.ReadCloser, error) {
+		*received = request
+		return request.URL, ioutil.NopCloser(strings.NewReader(document)), nil
+	}
+}
+
+func dictionaryXPathScraper() XPathScraperConfig {
+	return XPathScraperConfig{
+		Trigger:    "define",
+		Parameters: []Parameter{{Type: "string"}},
+		URL:        "https://example.com/dictionary/%s.xml",
+		Namespaces: map[string]string{"dict": "urn:example:dictionary"},
+		TitleSelector: XPathCapture{
+			Template:  "%s",
+			Selectors: []string{"//dict:entry/@word"},
+		},
+		ReplySelector: XPathCapture{
+			Template:  "%s (%s)",
+			Selectors: []string{"//dict:sense/dict:gloss", "//dict:audio"},
+			Extract:   []string{"Text", "@href"},
+		},
+		Fields: []XPathFieldCapture{
+			{
+				Title:          XPathCapture{Template: "%s", Selectors: []string{"@pos"}},
+				Description:    XPathCapture{Template: "%s", Selectors: []string{"./dict:gloss"}},
+				HandleMultiple: "All",
+				Container:      "//dict:sense",
+				Max:            2,
+				Numbered:       true,
+			},
+		},
+	}
+}
+
+func TestXPathScraper(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := dictionaryXPathScraper()
+	if err := config.Validate(); err != nil {
+		t.Errorf("A reasonable config was invalid: %s", err)
+	}
+
+	var received utils.Request
+	command, err := config.CommandWithHTMLRequester(xmlRequester(dictionaryXML, &received))
+	if err != nil {
+		t.Errorf("An error occurred when making a reasonable command: %s", err)
+	}
+
+	command.Exec(testConversation, testSender, []interface{}{"bahay"}, nil, demoSender.SendMessage)
+	if received.URL != "https://example.com/dictionary/bahay.xml" {
+		t.Errorf("Unexpected request: %v", received)
+	}
+
+	resultMessage, _ := demoSender.PopMessage()
+	expected := service.Message{
+		Title:       "bahay",
+		Description: "house (https://example.com/dictionary/audio/bahay.ogg)",
+		URL:         "https://example.com/dictionary/bahay.xml",
+		Fields: []service.MessageField{
+			{Field: "1. noun", Value: "house", Inline: true},
+			{Field: "2. noun", Value: "home", Inline: true},
+		},
+	}
+
+	if diff := cmp.Diff(resultMessage, expected); diff != "" {
+		t.Errorf("Unexpected message: %s", diff)
+	}
+}
+
+func TestXPathScraperNamespacedAttribute(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	config := XPathScraperConfig{
+		Trigger:       "audio",
+		URL:           "https://example.com/dictionary.xml",
+		Namespaces:    map[string]string{"dict": "urn:example:dictionary", "link": "http://www.w3.org/1999/xlink"},
+		TitleSelector: XPathCapture{Template: "Audio"},
+		ReplySelector: XPathCapture{
+			Template:  "{{.link}} and {{.verb}}",
+			Selectors: []string{"//dict:audio/@link:href", "//dict:sense[@pos='verb']"},
+			Names:     []string{"link", "verb"},
+		},
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("A reasonable config was invalid: %s", err)
+	}
+
+	var received utils.Request
+	command, _ := config.CommandWithHTMLRequester(xmlRequester(dictionaryXML, &received))
+	command.Exec(testConversation, testSender, []interface{}{}, nil, demoSender.SendMessage)
+
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "audio/bahay.mp3 and to nest" {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
+
+func TestXPathScraperEncoding(t *testing.T) {
+	root, err := parseXML(strings.NewReader(`<?xml version="1.0" encoding="ISO-8859-1"?><word>café</word>`))
+	if err != nil {
+		t.Fatalf("Couldn't parse XML: %s", err)
+	}
+
+	if text := root.InnerText(); text != "café" {
+		t.Errorf("Text was %q", text)
+	}
+}
+
+func TestXPathScraperInvalidDocument(t *testing.T) {
+	demoSender := demoservice.DemoSender{}
+	testConversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	testSender := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+
+	var received utils.Request
+	command, _ := dictionaryXPathScraper().CommandWithHTMLRequester(xmlRequester("<entry><unclosed></entry>", &received))
+	command.Exec(testConversation, testSender, []interface{}{"bahay"}, nil, demoSender.SendMessage)
+
+	if resultMessage, _ := demoSender.PopMessage(); resultMessage.Description != "An error occurred when processing the webpage." {
+		t.Errorf("Unexpected message: %v", resultMessage)
+	}
+}
+
+func TestXPathScraperValidate(t *testing.T) {
+	tests := []XPathScraperConfig{
+		{Trigger: "define", URL: "https://example.com/%s.xml"},
+		{Trigger: "define", URL: "https://example.com/", ReplySelector: XPathCapture{Template: "%s", Selectors: []string{"//["}}},
+		{Trigger: "define", URL: "https://example.com/", ReplySelector: XPathCapture{Template: "%s", Selectors: []string{"//a"}, Extract: []string{"Markdown"}}},
+		{Trigger: "define", URL: "https://example.com/", Fields: []XPathFieldCapture{{HandleMultiple: "All", Container: "//("}}},
+		{Trigger: "define", URL: "https://example.com/", Fields: []XPathFieldCapture{{HandleMultiple: "All", Max: service.MaxFields + 1}}},
+	}
+
+	for _, test := range tests {
+		if err := test.Validate(); err == nil {
+			t.Errorf("Config %v should be invalid", test)
+		}
+
+		if _, err := test.Command(); err == nil {
+			t.Errorf("A command shouldn't be made from config %v", test)
+		}
+	}
+}
@@ -1,8 +1,7 @@
 package config
 
 import (
-	"bufio"
-	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -10,12 +9,21 @@ import (
 
 	"github.com/BKrajancic/boby/m/v2/src/command"
 	"github.com/BKrajancic/boby/m/v2/src/storage"
-	"github.com/BKrajancic/boby/m/v2/src/utils"
 )
 
-const jsonFilepath = "json_getter_config.json"
-const regexpFilepath = "regexp_scraper_config.json"
-const goqueryFilepath = "goquery_scraper_config.json"
+// Names of configuration files, which can have any extension of configExtensions.
+const commandsName = "commands"
+const commandsDir = "commands"
+const politenessName = "politeness"
+
+// ServiceConfigName is the name of the file that configures services (such as the discord
+// token). It is only loaded when the bot starts.
+const ServiceConfigName = "config"
+
+// Names of legacy files, where each file only contains one type of command.
+const jsonName = "json_getter_config"
+const regexpName = "regexp_scraper_config"
+const goqueryName = "goquery_scraper_config"
 
 // MakeExampleDir makes an example folder with example config files.
 func MakeExampleDir(dir string) error {
@@ -62,7 +70,7 @@ func MakeExampleDir(dir string) error {
 				},
 			},
 			Grouped:   true,
-			URL:       "https://",
+			URL:       "https://example.com/%s",
 			Help:      "Help message for cmd.",
 			HelpInput: "word",
 			RateLimit: command.RateLimitConfig{
@@ -81,7 +89,7 @@ func MakeExampleDir(dir string) error {
 			TitleTemplate: "Title: %s",
 			TitleCapture:  "<h1>(.*)</h1>",
 			ReplyCapture:  "<h1>(.*)</h1>",
-			URL:           "https://",
+			URL:           "https://example.com/%s",
 			Help:          "Help message for rx.",
 			HelpInput:     "[sentence]",
 		},
@@ -103,112 +111,211 @@ func MakeExampleDir(dir string) error {
 				Replacements:   []map[string]string{{"Heading": "Title"}},
 				HandleMultiple: "Random",
 			},
-			URL:       "https://",
+			URL:       "https://example.com/%s",
 			Help:      "Help message for rx.",
 			HelpInput: "[@sentence]",
 		},
 	}
 
-	if file, err := os.Create(path.Join(dir, jsonFilepath)); err == nil {
-		if bytes, err := json.MarshalIndent(jsonGetters, "", "    "); err == nil {
-			file.Write(bytes)
-		} else {
+	entries := []map[string]interface{}{}
+	for _, config := range jsonGetters {
+		entry, err := withType("json", config)
+		if err != nil {
 			return err
 		}
-	} else {
-		return err
+		entries = append(entries, entry)
 	}
 
-	if file, err := os.Create(path.Join(dir, regexpFilepath)); err == nil {
-		if bytes, err := json.MarshalIndent(regexpGetters, "", "    "); err == nil {
-			file.Write(bytes)
-		} else {
+	for _, config := range regexpGetters {
+		entry, err := withType("regexp", config)
+		if err != nil {
 			return err
 		}
-	} else {
-		return err
+		entries = append(entries, entry)
 	}
 
-	if file, err := os.Create(path.Join(dir, goqueryFilepath)); err == nil {
-		if bytes, err := json.MarshalIndent(goqueryGetters, "", "    "); err == nil {
-			file.Write(bytes)
-		} else {
+	for _, config := range goqueryGetters {
+		entry, err := withType("goquery", config)
+		if err != nil {
 			return err
 		}
-	} else {
+		entries = append(entries, entry)
+	}
+
+	filepath := path.Join(dir, commandsName+".json")
+	bytes, err := Marshal(filepath, entries)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return ioutil.WriteFile(filepath, bytes, 0644)
 }
 
-// ConfiguredBot uses files in configDir to return a bot ready for usage.
-// This bot is not attached to any storage or services.
-func ConfiguredBot(configDir string, storage *storage.Storage) ([]command.Command, error) {
-	commands := command.AdminCommands()
+// commandFiles returns the path of every file in configDir that contains commands, along
+// with the type of entries to assume when an entry has no type.
+// This includes the commands file, files in commandsDir, and the legacy files for each type.
+func commandFiles(configDir string) (filepaths []string, entryTypes []string, err error) {
+	if filepath := FindFile(configDir, commandsName); fileExists(filepath) {
+		filepaths = append(filepaths, filepath)
+		entryTypes = append(entryTypes, "")
+	}
 
-	file, err := os.Open(path.Join(configDir, jsonFilepath))
-	if err != nil {
-		return commands, err
+	dirFiles, err := ioutil.ReadDir(path.Join(configDir, commandsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
 	}
 
-	bytes, err := ioutil.ReadAll(file)
-	if err != nil {
-		return commands, err
+	for _, file := range dirFiles {
+		if !file.IsDir() && isConfigFile(file.Name()) {
+			filepaths = append(filepaths, path.Join(configDir, commandsDir, file.Name()))
+			entryTypes = append(entryTypes, "")
+		}
 	}
 
-	// Get JSON getters.
-	var jsonGetters []command.JSONGetterConfig
-	if err = json.Unmarshal(bytes, &jsonGetters); err != nil {
-		return commands, err
+	legacyFiles := []struct {
+		filepath  string
+		entryType string
+	}{
+		{jsonName, "json"},
+		{regexpName, "regexp"},
+		{goqueryName, "goquery"},
 	}
 
-	for _, jsonGetter := range jsonGetters {
-		command, err := jsonGetter.Command(utils.JSONGetWithHTTP)
-		if err != nil {
-			return commands, err
+	for _, legacy := range legacyFiles {
+		if filepath := FindFile(configDir, legacy.filepath); fileExists(filepath) {
+			filepaths = append(filepaths, filepath)
+			entryTypes = append(entryTypes, legacy.entryType)
 		}
-		commands = append(commands, jsonGetter.RateLimit.GetRateLimitedCommand(command))
 	}
 
-	// Get regex scraper.
-	file, err = os.Open(path.Join(configDir, regexpFilepath))
-	if err != nil {
-		return commands, err
+	if len(filepaths) == 0 {
+		return nil, nil, fmt.Errorf("no command files were found in %s", configDir)
+	}
+
+	return filepaths, entryTypes, nil
+}
+
+// fileExists returns true if a file exists at filepath.
+func fileExists(filepath string) bool {
+	_, err := os.Stat(filepath)
+	return err == nil
+}
+
+// loadCommands loads every command in configDir, and returns every problem found.
+// Commands with problems are skipped.
+func loadCommands(configDir string) ([]command.Command, command.Errors) {
+	commands := command.AdminCommands()
+	origins := make(map[string]string)
+	for _, adminCommand := range commands {
+		origins[adminCommand.Trigger] = "admin commands"
+	}
+
+	for _, trigger := range command.BuiltinTriggers() {
+		origins[trigger] = "builtin commands"
 	}
 
-	regexScraperConfigs, err := command.GetRegexpScraperConfigs(bufio.NewReader(file))
+	filepaths, entryTypes, err := commandFiles(configDir)
 	if err != nil {
-		return commands, err
+		return commands, command.Errors{err}
 	}
 
-	for _, regexScraperConfig := range regexScraperConfigs {
-		command, err := regexScraperConfig.Command()
+	errs := command.Errors{}
+	for i, filepath := range filepaths {
+		src, err := readSource(filepath)
 		if err != nil {
-			return commands, err
+			errs = append(errs, err)
+			continue
+		}
+
+		loaded, loadErrs := loadEntries(src, entryTypes[i])
+		errs = append(errs, loadErrs...)
+
+		for _, loadedCommand := range loaded {
+			trigger := loadedCommand.command.Trigger
+			if origin, ok := origins[trigger]; ok {
+				errs = append(errs, fmt.Errorf(
+					"%s: duplicate Trigger \"%s\", already used by %s",
+					loadedCommand.origin,
+					trigger,
+					origin,
+				))
+				continue
+			}
+
+			origins[trigger] = loadedCommand.origin
+			commands = append(commands, redactedCommand(loadedCommand.command))
 		}
-		commands = append(commands, command)
 	}
 
-	file, err = os.Open(path.Join(configDir, goqueryFilepath))
-	if err != nil {
-		return commands, err
+	return commands, errs
+}
+
+// loadPoliteness loads the politeness file in configDir, which describes how requests are
+// made to each host. If there's no politeness file, there are no limits.
+func loadPoliteness(configDir string) (command.PolitenessConfig, error) {
+	var politeness command.PolitenessConfig
+	filepath := FindFile(configDir, politenessName)
+	if !fileExists(filepath) {
+		return politeness, nil
 	}
 
-	goqueryScraperConfigs, err := command.GetGoqueryScraperConfigs(bufio.NewReader(file))
+	contents, err := ioutil.ReadFile(filepath)
 	if err != nil {
-		return commands, err
+		return politeness, err
 	}
 
-	for _, goqueryScraperConfig := range goqueryScraperConfigs {
-		scraperCommand, err := goqueryScraperConfig.Command()
-		if err != nil {
-			return commands, err
-		}
-		commands = append(commands, scraperCommand)
+	if err := Unmarshal(filepath, contents, &politeness); err != nil {
+		return politeness, err
+	}
+
+	if err := politeness.Validate(); err != nil {
+		return politeness, fmt.Errorf("%s: %w", filepath, err)
+	}
+	return politeness, nil
+}
+
+// ConfiguredBot uses files in configDir to return a bot ready for usage.
+// This bot is not attached to any storage or services.
+//
+// Commands are loaded from the commands file and every file in commandsDir, where each
+// entry has a "Type" used to find its CommandLoader. For compatibility, the legacy files
+// (such as json_getter_config.json) are also loaded if they exist.
+// Each file can be either JSON or YAML, depending on its extension.
+//
+// If there's a politeness file, it is used to limit how requests are made to each host
+// (see command.PolitenessConfig).
+//
+// Any string can reference a secret, such as "${API_KEY}" or "${file:api_key.txt}" (see
+// ResolveSecrets). Secrets are redacted from the problems that are returned, and from every
+// message that commands send.
+//
+// If any problems are found, they are all returned as a command.Errors, along with every
+// command that was loaded without a problem.
+func ConfiguredBot(configDir string, storage *storage.Storage) ([]command.Command, error) {
+	commands, errs := loadCommands(configDir)
+
+	politeness, err := loadPoliteness(configDir)
+	if err != nil {
+		errs = append(errs, err)
 	}
 
 	// TODO: Helptext is hardcoded for discord, and is therefore a leaky abstraction.
 
+	if len(errs) > 0 {
+		return commands, redactErrors(errs)
+	}
+
+	// Politeness is only used once the whole configuration is accepted, so a rejected reload
+	// doesn't change how requests are made.
+	command.DefaultFetcher.SetConfig(politeness)
 	return commands, nil
 }
+
+// Validate loads every configuration file in configDir, and returns every problem found.
+func Validate(configDir string) []error {
+	_, errs := loadCommands(configDir)
+	if _, err := loadPoliteness(configDir); err != nil {
+		errs = append(errs, err)
+	}
+	return redactErrors(errs)
+}
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/command"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+// commandsFile is the name of a JSON commands file.
+const commandsFile = commandsName + ".json"
+
+// writeFiles writes each file in files (a filepath to content map) to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for filepath, content := range files {
+		fullpath := path.Join(dir, filepath)
+		if err := os.MkdirAll(path.Dir(fullpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(fullpath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// triggers returns the triggers of commands that aren't admin commands.
+func triggers(commands []command.Command) []string {
+	out := []string{}
+	for _, cmd := range commands[len(command.AdminCommands()):] {
+		out = append(out, cmd.Trigger)
+	}
+	return out
+}
+
+func configuredBot(dir string) ([]command.Command, error) {
+	tempStorage := storage.GetTempStorage()
+	var _storage storage.Storage = &tempStorage
+	return ConfiguredBot(dir, &_storage)
+}
+
+func TestUnifiedCommandsFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+			{"Type": "json", "Trigger": "j", "URL": "https://"},
+			{"type": "regexp", "Trigger": "r", "URL": "https://", "ReplyCapture": "(.*)"},
+			{"Type": "goquery", "Trigger": "g", "URL": "https://"}
+		]`,
+	})
+
+	commands, err := configuredBot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(triggers(commands), ",") != "j,r,g" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
+
+func TestCommandsDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile:                     `[{"Type": "json", "Trigger": "main", "URL": "https://"}]`,
+		path.Join(commandsDir, "a.json"): `[{"Type": "json", "Trigger": "a", "URL": "https://"}]`,
+		path.Join(commandsDir, "b.JSON"): `[{"Type": "goquery", "Trigger": "b", "URL": "https://"}]`,
+		path.Join(commandsDir, "c.txt"):  `not a commands file`,
+		path.Join(commandsDir, "d.json"): `[]`,
+	})
+
+	commands, err := configuredBot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(triggers(commands), ",") != "main,a,b" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
+
+func TestLegacyFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		jsonName + ".json":    `[{"Trigger": "j", "URL": "https://"}]`,
+		goqueryName + ".json": `[{"Trigger": "g", "URL": "https://"}]`,
+	})
+
+	commands, err := configuredBot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(triggers(commands), ",") != "j,g" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
+
+func TestNoCommandFiles(t *testing.T) {
+	if _, err := configuredBot(t.TempDir()); err == nil {
+		t.Errorf("A directory without commands should be an error")
+	}
+}
+
+func TestUnknownType(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[{"Type": "json", "Trigger": "j"}, {"Type": "unknown", "Trigger": "u"}]`,
+	})
+
+	_, err := configuredBot(dir)
+	if err == nil || !strings.Contains(err.Error(), "entry 1") || !strings.Contains(err.Error(), commandsFile) {
+		t.Errorf("Error should identify the file and entry: %v", err)
+	}
+}
+
+func TestMissingType(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{commandsFile: `[{"Trigger": "j"}]`})
+
+	if _, err := configuredBot(dir); err == nil {
+		t.Errorf("An entry without a type should be an error")
+	}
+}
+
+func TestCustomLoader(t *testing.T) {
+	CommandLoaders["custom"] = func(entry []byte) (command.Command, error) {
+		return command.Command{Trigger: "custom"}, nil
+	}
+	defer delete(CommandLoaders, "custom")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{commandsFile: `[{"Type": "custom"}]`})
+
+	commands, err := configuredBot(dir)
+	if err != nil || strings.Join(triggers(commands), ",") != "custom" {
+		t.Fail()
+	}
+}
+
+func TestMakeExampleDir(t *testing.T) {
+	dir := path.Join(t.TempDir(), "example")
+	if err := MakeExampleDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, err := configuredBot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(triggers(commands), ",") != "cmd,rx,gq" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
+
+func TestEveryProblemReported(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+			{"Type": "json", "Trigger": "", "URL": "https://"},
+			{"Type": "regexp", "Trigger": "r", "URL": "https://", "ReplyCapture": "("},
+			{"Type": "goquery", "Trigger": "g", "URL": "https://"}
+		]`,
+	})
+
+	commands, err := configuredBot(dir)
+	errs, ok := err.(command.Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected 2 problems, got: %v", err)
+	}
+
+	if !strings.Contains(errs[0].Error(), commandsFile+":2: entry 0") {
+		t.Errorf("Error should identify the file, line and entry: %s", errs[0])
+	}
+
+	if !strings.Contains(errs[1].Error(), commandsFile+":3: entry 1 (r)") {
+		t.Errorf("Error should identify the file, line and entry: %s", errs[1])
+	}
+
+	if strings.Join(triggers(commands), ",") != "g" {
+		t.Errorf("Commands without problems should still be loaded: %v", triggers(commands))
+	}
+}
+
+func TestDuplicateTrigger(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile:                     `[{"Type": "json", "Trigger": "a", "URL": "https://"}]`,
+		path.Join(commandsDir, "a.json"): `[{"Type": "goquery", "Trigger": "a", "URL": "https://"}]`,
+	})
+
+	_, err := configuredBot(dir)
+	if err == nil || !strings.Contains(err.Error(), "duplicate Trigger \"a\"") {
+		t.Errorf("A duplicate trigger should be an error: %v", err)
+	}
+
+	for _, trigger := range []string{command.HelpTrigger, command.SetupTrigger, command.SetPresenceTrigger, command.ReloadTrigger} {
+		writeFiles(t, dir, map[string]string{
+			commandsFile: `[{"Type": "json", "Trigger": "` + trigger + `", "URL": "https://"}]`,
+		})
+
+		_, err := configuredBot(dir)
+		if err == nil || !strings.Contains(err.Error(), "already used by builtin commands") {
+			t.Errorf("%s is used by a builtin command, so should be an error: %v", trigger, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := path.Join(t.TempDir(), "example")
+	if err := MakeExampleDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := Validate(dir); len(errs) != 0 {
+		t.Errorf("The example should be valid: %v", errs)
+	}
+
+	if errs := Validate(t.TempDir()); len(errs) != 1 {
+		t.Errorf("A directory without commands should be a problem: %v", errs)
+	}
+}
+
+func TestPoliteness(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile:      `[]`,
+		"politeness.yaml": "Default:\n  Concurrency: 2\nHosts:\n  example.com:\n    RequestsPerSecond: -1\n",
+	})
+
+	if errs := Validate(dir); len(errs) != 1 || !strings.Contains(errs[0].Error(), "politeness.yaml") {
+		t.Errorf("A problem with the politeness file should be reported: %v", errs)
+	}
+
+	writeFiles(t, dir, map[string]string{"politeness.yaml": "Default:\n  Concurrency: 2\n"})
+	if _, err := configuredBot(dir); err != nil {
+		t.Errorf("A valid politeness file should load: %s", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+			{"Type": "fallback", "Trigger": "define", "Parameters": [{"Type": "string"}], "Sources": [
+				{"Type": "goquery", "URL": "https://first.example.com/%s"},
+				{"Type": "json", "Name": "Second", "URL": "https://second.example.com/%s"}
+			]},
+			{"Type": "fallback", "Trigger": "empty"},
+			{"Type": "fallback", "Trigger": "bad", "Sources": [{"Type": "regexp", "URL": "https://", "ReplyCapture": "("}, {"URL": "https://"}]}
+		]`,
+	})
+
+	commands, err := configuredBot(dir)
+	errs, ok := err.(command.Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("Expected 3 problems, got: %v", err)
+	}
+
+	if !strings.Contains(errs[1].Error(), "entry 2 (bad): Sources[0]: invalid ReplyCapture") {
+		t.Errorf("Problems with a source should identify it: %s", errs[1])
+	}
+
+	if !strings.Contains(errs[2].Error(), "Sources[1]: missing \"Type\"") {
+		t.Errorf("Problems with a source should identify it: %s", errs[2])
+	}
+
+	if strings.Join(triggers(commands), ",") != "define" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+			{"Type": "aggregate", "Trigger": "lookup", "Parameters": [{"Type": "string"}], "Timeout": 5, "Sources": [
+				{"Type": "goquery", "URL": "https://first.example.com/%s"},
+				{"Type": "fallback", "Sources": [{"Type": "json", "URL": "https://second.example.com/%s"}]}
+			]},
+			{"Type": "aggregate", "Trigger": "slow", "Timeout": -1, "Sources": []}
+		]`,
+	})
+
+	commands, err := configuredBot(dir)
+	errs, ok := err.(command.Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected 2 problems, got: %v", err)
+	}
+
+	if strings.Join(triggers(commands), ",") != "lookup" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
+
+func TestFeed(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+			{"Type": "feed", "Trigger": "news", "Parameters": [{"Type": "string"}], "URL": "https://example.com/%s.xml", "Items": 3},
+			{"Type": "feed", "Trigger": "blog", "URL": "https://example.com/feed.xml", "SummaryLength": -1}
+		]`,
+	})
+
+	commands, err := configuredBot(dir)
+	errs, ok := err.(command.Errors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected 1 problem, got: %v", err)
+	}
+
+	if strings.Join(triggers(commands), ",") != "news" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
+
+func TestXPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+			{"Type": "xpath", "Trigger": "define", "Parameters": [{"Type": "string"}], "URL": "https://example.com/%s.xml",
+				"Namespaces": {"d": "urn:example:dictionary"},
+				"ReplySelector": {"Template": "%s", "Selectors": ["//d:gloss"]}},
+			{"Type": "xpath", "Trigger": "broken", "URL": "https://example.com/", "ReplySelector": {"Template": "%s", "Selectors": ["//["]}}
+		]`,
+	})
+
+	commands, err := configuredBot(dir)
+	errs, ok := err.(command.Errors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected 1 problem, got: %v", err)
+	}
+
+	if strings.Join(triggers(commands), ",") != "define" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions of files that can be used for configuration, in order of preference.
+var configExtensions = []string{".json", ".yaml", ".yml"}
+
+// isYAML returns true if filepath should be read as YAML, rather than JSON.
+func isYAML(filepath string) bool {
+	ext := strings.ToLower(path.Ext(filepath))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// isConfigFile returns true if filepath has the extension of a configuration file.
+func isConfigFile(filepath string) bool {
+	ext := strings.ToLower(path.Ext(filepath))
+	for _, configExtension := range configExtensions {
+		if ext == configExtension {
+			return true
+		}
+	}
+	return false
+}
+
+// FindFile returns the path of a configuration file in dir named name, with any supported
+// extension (such as name.json or name.yaml). If no such file exists, the path with a .json
+// extension is returned.
+func FindFile(dir string, name string) string {
+	for _, ext := range configExtensions {
+		filepath := path.Join(dir, name+ext)
+		if _, err := os.Stat(filepath); err == nil {
+			return filepath
+		}
+	}
+	return path.Join(dir, name+configExtensions[0])
+}
+
+// lineOffset records that JSON from offset onwards came from line of a file.
+type lineOffset struct {
+	offset int64
+	line   int
+}
+
+// A source is the contents of a configuration file, converted to JSON.
+// It is able to find which line of the original file any part of the JSON came from.
+type source struct {
+	filepath string
+	json     []byte
+	lines    []lineOffset // Sorted by offset. If empty, json is the original file.
+}
+
+// line returns the line of the original file that offset (of the JSON) came from.
+func (s source) line(offset int64) int {
+	if len(s.lines) == 0 {
+		if offset > int64(len(s.json)) {
+			offset = int64(len(s.json))
+		}
+		return bytes.Count(s.json[:offset], []byte("\n")) + 1
+	}
+
+	i := sort.Search(len(s.lines), func(i int) bool { return s.lines[i].offset > offset })
+	if i == 0 {
+		return s.lines[0].line
+	}
+	return s.lines[i-1].line
+}
+
+// errorf returns an error including this source's filepath, and the line that offset came
+// from. If offset is negative, the line is omitted.
+func (s source) errorf(offset int64, format string, a ...interface{}) error {
+	if offset < 0 {
+		return fmt.Errorf("%s: %w", s.filepath, fmt.Errorf(format, a...))
+	}
+	return fmt.Errorf("%s:%d: %w", s.filepath, s.line(offset), fmt.Errorf(format, a...))
+}
+
+// errorOffset returns the offset at which a json error occurred, or -1 if it isn't known.
+func errorOffset(err error) int64 {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Offset
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return typeErr.Offset
+	}
+
+	return -1
+}
+
+// readSource reads a configuration file, which is YAML if it has a YAML extension,
+// otherwise it is JSON.
+func readSource(filepath string) (source, error) {
+	contents, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return source{}, err
+	}
+	return newSource(filepath, contents)
+}
+
+// newSource makes a source from the contents of a configuration file.
+func newSource(filepath string, contents []byte) (source, error) {
+	src := source{filepath: filepath, json: contents}
+	if !isYAML(filepath) {
+		return src, nil
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(contents, &node); err != nil {
+		return src, fmt.Errorf("%s: %w", filepath, err)
+	}
+
+	var buffer bytes.Buffer
+	if err := src.writeYAMLNode(&buffer, &node); err != nil {
+		return src, err
+	}
+
+	src.json = buffer.Bytes()
+	if len(src.json) == 0 {
+		src.json = []byte("null")
+	}
+	return src, nil
+}
+
+// writeYAMLNode writes node as JSON to buffer, recording what line each part came from.
+func (s *source) writeYAMLNode(buffer *bytes.Buffer, node *yaml.Node) error {
+	s.lines = append(s.lines, lineOffset{offset: int64(buffer.Len()), line: node.Line})
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := s.writeYAMLNode(buffer, child); err != nil {
+				return err
+			}
+		}
+
+	case yaml.AliasNode:
+		return s.writeYAMLNode(buffer, node.Alias)
+
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, child := range node.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := s.writeYAMLNode(buffer, child); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+
+	case yaml.MappingNode:
+		buffer.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", s.filepath, node.Content[i].Line, err)
+			}
+			buffer.Write(key)
+			buffer.WriteByte(':')
+
+			if err := s.writeYAMLNode(buffer, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+
+	case yaml.ScalarNode:
+		var value interface{} = node.Value
+		if node.Tag != "!!str" {
+			if err := node.Decode(&value); err != nil {
+				return fmt.Errorf("%s:%d: %w", s.filepath, node.Line, err)
+			}
+		}
+
+		scalar, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", s.filepath, node.Line, err)
+		}
+		buffer.Write(scalar)
+	}
+
+	return nil
+}
+
+// Unmarshal parses the contents of a configuration file into v.
+// The file is YAML if filepath has a YAML extension, otherwise it is JSON.
+// References to secrets in strings are resolved (see ResolveSecrets).
+// Errors include the filepath, and where possible, the line of the problem.
+func Unmarshal(filepath string, contents []byte, v interface{}) error {
+	src, err := newSource(filepath, contents)
+	if err != nil {
+		return err
+	}
+
+	if src.json, err = resolveJSONSecrets(src.json, path.Dir(filepath)); err != nil {
+		return src.errorf(-1, "%w", err)
+	}
+
+	if err := json.Unmarshal(src.json, v); err != nil {
+		return src.errorf(errorOffset(err), "%w", err)
+	}
+	return nil
+}
+
+// Marshal returns the contents of a configuration file for v, which is YAML if filepath has
+// a YAML extension, otherwise it is indented JSON.
+func Marshal(filepath string, v interface{}) ([]byte, error) {
+	contents, err := json.MarshalIndent(v, "", "    ")
+	if err != nil || !isYAML(filepath) {
+		return contents, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(contents, &generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/command"
+)
+
+func TestYAMLCommandsFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsName + ".yaml": `
+- Type: goquery
+  Trigger: g
+  URL: "https://"
+  ReplySelector:
+    Template: "%s"
+    Selectors: [".meaning"]
+- Type: regexp
+  Trigger: r
+  URL: https://
+  ReplyCapture: <h1>(.*)</h1>
+`,
+		path.Join(commandsDir, "more.yml"): `[{Type: json, Trigger: j, URL: "https://"}]`,
+	})
+
+	commands, err := configuredBot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(triggers(commands), ",") != "g,r,j" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+}
+
+func TestYAMLErrorLine(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsName + ".yaml": `
+- Type: json
+  Trigger: a
+- Type: json
+  Trigger: b
+  Grouped: "not a bool"
+`,
+	})
+
+	_, err := configuredBot(dir)
+	if err == nil || !strings.Contains(err.Error(), commandsName+".yaml:6:") {
+		t.Errorf("Error should include the file and line: %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "entry 1") {
+		t.Errorf("Error should include the entry: %v", err)
+	}
+}
+
+func TestYAMLSyntaxError(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{commandsName + ".yml": "- Type: json\n  Trigger: [a\n"})
+
+	_, err := configuredBot(dir)
+	if err == nil || !strings.Contains(err.Error(), commandsName+".yml") || !strings.Contains(err.Error(), "line") {
+		t.Errorf("Error should include the file and line: %v", err)
+	}
+}
+
+func TestJSONErrorLine(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+	{"Type": "json", "Trigger": "a"},
+	{
+		"Type": "json",
+		"Trigger": 5
+	}
+]`,
+	})
+
+	_, err := configuredBot(dir)
+	if err == nil || !strings.Contains(err.Error(), commandsFile+":5:") {
+		t.Errorf("Error should include the file and line: %v", err)
+	}
+}
+
+func TestJSONSyntaxErrorLine(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{commandsFile: "[\n{\"Type\": \"json\"},\n{\"Type\" \"json\"}\n]"})
+
+	_, err := configuredBot(dir)
+	if err == nil || !strings.Contains(err.Error(), commandsFile+":3:") {
+		t.Errorf("Error should include the file and line: %v", err)
+	}
+}
+
+func TestNotAList(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{commandsFile: `{"Type": "json"}`})
+
+	if _, err := configuredBot(dir); err == nil {
+		t.Errorf("A commands file must be a list")
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var configs []command.GoQueryScraperConfig
+	yaml := "- Trigger: a\n  HideURL: true\n  Parameters:\n    - Type: string\n"
+	if err := Unmarshal("file.yaml", []byte(yaml), &configs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(configs) != 1 || configs[0].Trigger != "a" || !configs[0].HideURL || configs[0].Parameters[0].Type != "string" {
+		t.Errorf("YAML was not parsed correctly: %v", configs)
+	}
+
+	err := Unmarshal("file.json", []byte("[\n{\"HideURL\": 1}]"), &configs)
+	if err == nil || !strings.HasPrefix(err.Error(), "file.json:2:") {
+		t.Errorf("Error should include the file and line: %v", err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	config := command.RegexpScraperConfig{Trigger: "rx"}
+	for _, filepath := range []string{"file.json", "file.yaml"} {
+		contents, err := Marshal(filepath, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result command.RegexpScraperConfig
+		if err := Unmarshal(filepath, contents, &result); err != nil || result.Trigger != "rx" {
+			t.Errorf("Marshal should be reversible with Unmarshal for %s", filepath)
+		}
+	}
+}
+
+func TestFindFile(t *testing.T) {
+	dir := t.TempDir()
+	if FindFile(dir, "config") != path.Join(dir, "config.json") {
+		t.Errorf("JSON should be used by default")
+	}
+
+	writeFiles(t, dir, map[string]string{"config.yml": ""})
+	if FindFile(dir, "config") != path.Join(dir, "config.yml") {
+		t.Errorf("YAML should be found")
+	}
+}
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/BKrajancic/boby/m/v2/src/command"
+)
+
+// A CommandLoader makes a Command from a single entry of a commands file.
+// The entry is the JSON of a config, which may include its "Type" field.
+// If the config has several problems, they should be returned as command.Errors.
+type CommandLoader func(entry []byte) (command.Command, error)
+
+// CommandLoaders maps the Type of an entry in a commands file to what is used to load it.
+// New types of commands are supported by adding to this map.
+var CommandLoaders = map[string]CommandLoader{
+	"json":    loadJSONGetter,
+	"regexp":  loadRegexpScraper,
+	"goquery": loadGoqueryScraper,
+	"feed":    loadFeed,
+	"xpath":   loadXPathScraper,
+}
+
+func init() {
+	// Added here, as loadFallback and loadAggregate use CommandLoaders to load its sources.
+	CommandLoaders["fallback"] = loadFallback
+	CommandLoaders["aggregate"] = loadAggregate
+}
+
+// typedEntry is used to find the type (and trigger) of an entry in a commands file.
+type typedEntry struct {
+	Type    string
+	Trigger string
+}
+
+// A loadedCommand is a command, along with a description of where it was loaded from.
+type loadedCommand struct {
+	command command.Command
+	origin  string
+}
+
+// loadJSONGetter makes a Command from the JSON of a command.JSONGetterConfig.
+func loadJSONGetter(entry []byte) (command.Command, error) {
+	var config command.JSONGetterConfig
+	if err := json.Unmarshal(entry, &config); err != nil {
+		return command.Command{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return command.Command{}, err
+	}
+
+	jsonCommand, err := config.CommandWithRequester(command.JSONGetWithRequest)
+	if err != nil {
+		return command.Command{}, err
+	}
+	return config.RateLimit.GetRateLimitedCommand(jsonCommand), nil
+}
+
+// loadRegexpScraper makes a Command from the JSON of a command.RegexpScraperConfig.
+func loadRegexpScraper(entry []byte) (command.Command, error) {
+	var config command.RegexpScraperConfig
+	if err := json.Unmarshal(entry, &config); err != nil {
+		return command.Command{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return command.Command{}, err
+	}
+	return config.Command()
+}
+
+// loadGoqueryScraper makes a Command from the JSON of a command.GoQueryScraperConfig.
+func loadGoqueryScraper(entry []byte) (command.Command, error) {
+	var config command.GoQueryScraperConfig
+	if err := json.Unmarshal(entry, &config); err != nil {
+		return command.Command{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return command.Command{}, err
+	}
+	return config.Command()
+}
+
+// loadXPathScraper makes a Command from the JSON of a command.XPathScraperConfig.
+func loadXPathScraper(entry []byte) (command.Command, error) {
+	var config command.XPathScraperConfig
+	if err := json.Unmarshal(entry, &config); err != nil {
+		return command.Command{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return command.Command{}, err
+	}
+	return config.Command()
+}
+
+// loadFeed makes a Command from the JSON of a command.FeedConfig.
+func loadFeed(entry []byte) (command.Command, error) {
+	var config command.FeedConfig
+	if err := json.Unmarshal(entry, &config); err != nil {
+		return command.Command{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return command.Command{}, err
+	}
+	return config.Command()
+}
+
+// fallbackEntry is the JSON of a command.FallbackConfig, along with its sources, which are
+// entries of any other type.
+type fallbackEntry struct {
+	command.FallbackConfig
+	Sources []json.RawMessage
+}
+
+// loadFallback makes a Command from the JSON of a command.FallbackConfig, loading each of its
+// sources with CommandLoaders.
+func loadFallback(entry []byte) (command.Command, error) {
+	var config fallbackEntry
+	if err := json.Unmarshal(entry, &config); err != nil {
+		return command.Command{}, err
+	}
+
+	errs := command.AppendError(command.Errors{}, config.Validate())
+
+	sources, sourceErrs := loadSources(config.Sources, config.Trigger, config.Parameters)
+	if errs = append(errs, sourceErrs...); len(errs) > 0 {
+		return command.Command{}, errs
+	}
+	return config.Command(sources)
+}
+
+// aggregateEntry is the JSON of a command.AggregateConfig, along with its sources, which are
+// entries of any other type.
+type aggregateEntry struct {
+	command.AggregateConfig
+	Sources []json.RawMessage
+}
+
+// loadAggregate makes a Command from the JSON of a command.AggregateConfig, loading each of its
+// sources with CommandLoaders.
+func loadAggregate(entry []byte) (command.Command, error) {
+	var config aggregateEntry
+	if err := json.Unmarshal(entry, &config); err != nil {
+		return command.Command{}, err
+	}
+
+	errs := command.AppendError(command.Errors{}, config.Validate())
+
+	sources, sourceErrs := loadSources(config.Sources, config.Trigger, config.Parameters)
+	if errs = append(errs, sourceErrs...); len(errs) > 0 {
+		return command.Command{}, errs
+	}
+	return config.Command(sources)
+}
+
+// loadSources makes a source from the JSON of each entry of sources, and returns every problem
+// found. There must be at least one source.
+func loadSources(sources []json.RawMessage, trigger string, parameters []command.Parameter) ([]command.Source, command.Errors) {
+	errs := command.Errors{}
+	if len(sources) == 0 {
+		errs = append(errs, fmt.Errorf("at least one of Sources is needed"))
+	}
+
+	loaded := []command.Source{}
+	for i, raw := range sources {
+		source, err := loadSource(raw, trigger, parameters)
+		if problems, ok := err.(command.Errors); ok {
+			for _, problem := range problems {
+				errs = append(errs, fmt.Errorf("Sources[%d]: %w", i, problem))
+			}
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("Sources[%d]: %w", i, err))
+		} else {
+			loaded = append(loaded, source)
+		}
+	}
+	return loaded, errs
+}
+
+// loadSource makes a source from its JSON, which uses trigger and parameters unless it has
+// its own Trigger and Parameters. If the source has no "Name", the host of its URL is used,
+// otherwise its type.
+func loadSource(raw json.RawMessage, trigger string, parameters []command.Parameter) (command.Source, error) {
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return command.Source{}, err
+	}
+
+	entryType, _ := entry["Type"].(string)
+	loader, ok := CommandLoaders[entryType]
+	if entryType == "" {
+		return command.Source{}, fmt.Errorf("missing \"Type\"")
+	} else if !ok {
+		return command.Source{}, fmt.Errorf("unknown type \"%s\"", entryType)
+	}
+
+	if _, ok := entry["Trigger"]; !ok {
+		entry["Trigger"] = trigger
+	}
+
+	if _, ok := entry["Parameters"]; !ok {
+		entry["Parameters"] = parameters
+	}
+
+	name, _ := entry["Name"].(string)
+	if name == "" {
+		name = entryType
+		if rawURL, ok := entry["URL"].(string); ok {
+			if parsed, err := url.Parse(rawURL); err == nil && parsed.Host != "" {
+				name = parsed.Host
+			}
+		}
+	}
+
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return command.Source{}, err
+	}
+
+	loaded, err := loader(bytes)
+	if err != nil {
+		return command.Source{}, err
+	}
+	return command.Source{Name: name, Command: loaded}, nil
+}
+
+// loadEntries makes a Command for each entry of a commands file.
+// If entryType isn't empty, it is used for entries that have no "Type".
+// Entries with problems are skipped, and every problem is returned including the file,
+// line and index of the entry that caused it.
+func loadEntries(src source, entryType string) ([]loadedCommand, command.Errors) {
+	commands := []loadedCommand{}
+	decoder := json.NewDecoder(bytes.NewReader(src.json))
+
+	if token, err := decoder.Token(); err != nil {
+		return commands, command.Errors{src.errorf(errorOffset(err), "%w", err)}
+	} else if token != json.Delim('[') {
+		return commands, command.Errors{src.errorf(0, "expected a list of commands")}
+	}
+
+	errs := command.Errors{}
+	for i := 0; decoder.More(); i++ {
+		start := decoder.InputOffset()
+		var entry json.RawMessage
+		if err := decoder.Decode(&entry); err != nil {
+			return commands, append(errs, src.errorf(errorOffset(err), "entry %d: %w", i, err))
+		}
+
+		// The offset may be before a separating comma and whitespace.
+		start += int64(bytes.IndexAny(src.json[start:], "{[\"tfn0123456789-"))
+
+		resolved, err := resolveJSONSecrets(entry, path.Dir(src.filepath))
+		if err != nil {
+			errs = append(errs, src.errorf(start, "entry %d: %w", i, err))
+			continue
+		}
+
+		// Offsets within an entry with secrets don't match the file, so only its start is used.
+		offset := func(err error) int64 { return entryOffset(start, err) }
+		if !bytes.Equal(resolved, entry) {
+			offset = func(error) int64 { return start }
+		}
+		entry = resolved
+
+		typed := typedEntry{Type: entryType}
+		if err := json.Unmarshal(entry, &typed); err != nil {
+			errs = append(errs, src.errorf(offset(err), "entry %d: %w", i, err))
+			continue
+		}
+
+		name := fmt.Sprintf("entry %d", i)
+		if typed.Trigger != "" {
+			name = fmt.Sprintf("entry %d (%s)", i, typed.Trigger)
+		}
+
+		if typed.Type == "" {
+			errs = append(errs, src.errorf(start, "%s: missing \"Type\"", name))
+			continue
+		}
+
+		loader, ok := CommandLoaders[typed.Type]
+		if !ok {
+			errs = append(errs, src.errorf(start, "%s: unknown type \"%s\"", name, typed.Type))
+			continue
+		}
+
+		loaded, err := loader(entry)
+		if problems, ok := err.(command.Errors); ok {
+			for _, problem := range problems {
+				errs = append(errs, src.errorf(offset(problem), "%s: %w", name, problem))
+			}
+			continue
+		} else if err != nil {
+			errs = append(errs, src.errorf(offset(err), "%s: %w", name, err))
+			continue
+		}
+
+		commands = append(commands, loadedCommand{
+			command: loaded,
+			origin:  fmt.Sprintf("%s:%d: %s", src.filepath, src.line(start), name),
+		})
+	}
+
+	return commands, errs
+}
+
+// entryOffset returns the offset in a file of an error caused by an entry starting at start.
+// If the error has no offset, the start of the entry is used.
+func entryOffset(start int64, err error) int64 {
+	if offset := errorOffset(err); offset >= 0 {
+		return start + offset
+	}
+	return start
+}
+
+// withType returns the JSON of config, with a "Type" field of entryType.
+func withType(entryType string, config interface{}) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal(bytes, &entry); err != nil {
+		return nil, err
+	}
+
+	entry["Type"] = entryType
+	return entry, nil
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BKrajancic/boby/m/v2/src/command"
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+)
+
+// secretReference matches a reference to a secret, such as "${API_KEY}" or "${file:key.txt}".
+// A reference with an extra "$" (such as "$${API_KEY}") isn't a reference.
+var secretReference = regexp.MustCompile(`\$?\$\{([^{}]*)\}`)
+
+// secretFilePrefix starts a reference to a secret file, rather than an environment variable.
+const secretFilePrefix = "file:"
+
+// redacted replaces secrets that are redacted.
+const redacted = "[REDACTED]"
+
+// minSecretLength is the length of the shortest secret that is redacted. Shorter values
+// would redact ordinary text.
+const minSecretLength = 4
+
+// secrets are the values of every secret that has been resolved, so they can be redacted.
+var secrets = make(map[string]bool)
+
+// secretsMutex is locked when accessing secrets.
+var secretsMutex sync.Mutex
+
+// ResolveSecrets replaces every reference to a secret in text. "${NAME}" is replaced with the
+// environment variable NAME, and "${file:path}" is replaced with the contents of the file at
+// path (without a trailing newline), where a relative path is relative to dir.
+// Secrets that are resolved are redacted by Redact.
+func ResolveSecrets(text string, dir string) (string, error) {
+	var err error
+	resolved := secretReference.ReplaceAllStringFunc(text, func(reference string) string {
+		if strings.HasPrefix(reference, "$$") || err != nil {
+			return reference[1:]
+		}
+
+		name := secretReference.FindStringSubmatch(reference)[1]
+		var secret string
+		if filepath := strings.TrimPrefix(name, secretFilePrefix); filepath != name {
+			if !path.IsAbs(filepath) {
+				filepath = path.Join(dir, filepath)
+			}
+
+			contents, readErr := ioutil.ReadFile(filepath)
+			if readErr != nil {
+				err = fmt.Errorf("unable to read secret file: %w", readErr)
+				return reference
+			}
+			secret = strings.TrimRight(string(contents), "\r\n")
+		} else if value, ok := os.LookupEnv(name); ok {
+			secret = value
+		} else {
+			err = fmt.Errorf("the environment variable \"%s\" isn't set", name)
+			return reference
+		}
+
+		if len(secret) >= minSecretLength {
+			secretsMutex.Lock()
+			secrets[secret] = true
+			secretsMutex.Unlock()
+		}
+		return secret
+	})
+	return resolved, err
+}
+
+// resolveJSONSecrets resolves secrets in every string of a JSON (see ResolveSecrets).
+// If the JSON has no references, it is returned as it is.
+func resolveJSONSecrets(contents []byte, dir string) ([]byte, error) {
+	if !bytes.Contains(contents, []byte("${")) {
+		return contents, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	resolved, err := resolveValueSecrets(value, dir)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resolved)
+}
+
+// resolveValueSecrets resolves secrets in every string of a decoded JSON value.
+func resolveValueSecrets(value interface{}, dir string) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		return ResolveSecrets(value, dir)
+	case []interface{}:
+		for i, item := range value {
+			resolved, err := resolveValueSecrets(item, dir)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = resolved
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			resolved, err := resolveValueSecrets(item, dir)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			value[key] = resolved
+		}
+	}
+	return value, nil
+}
+
+// Redact replaces every secret that has been resolved in text with "[REDACTED]".
+func Redact(text string) string {
+	secretsMutex.Lock()
+	values := make([]string, 0, len(secrets))
+	for secret := range secrets {
+		values = append(values, secret)
+	}
+	secretsMutex.Unlock()
+
+	// Longer secrets are replaced first, in case they contain shorter secrets.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, secret := range values {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	return text
+}
+
+// RedactMessage returns msg, with secrets redacted from its text and URLs.
+func RedactMessage(msg service.Message) service.Message {
+	redactedMsg := service.Message{
+		URL:         Redact(msg.URL),
+		Title:       Redact(msg.Title),
+		Description: Redact(msg.Description),
+	}
+
+	for _, field := range msg.Fields {
+		field.Field = Redact(field.Field)
+		field.Value = Redact(field.Value)
+		field.URL = Redact(field.URL)
+		redactedMsg.Fields = append(redactedMsg.Fields, field)
+	}
+	return redactedMsg
+}
+
+// redactedCommand returns cmd, with secrets redacted from every message it sends. A secret in
+// a URL is sent to the server it belongs to, but not shown to users, such as in the URL of a
+// result or an error.
+func redactedCommand(cmd command.Command) command.Command {
+	exec := cmd.Exec
+	cmd.Exec = func(sender service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+		exec(sender, user, msg, storage, func(conversation service.Conversation, reply service.Message) {
+			sink(conversation, RedactMessage(reply))
+		})
+	}
+	return cmd
+}
+
+// redactedError is an error with secrets redacted from its description.
+type redactedError struct {
+	err error
+}
+
+// Error describes the error, without secrets.
+func (r redactedError) Error() string {
+	return Redact(r.err.Error())
+}
+
+// Unwrap returns the original error.
+func (r redactedError) Unwrap() error {
+	return r.err
+}
+
+// redactErrors returns errs, with secrets redacted from each of them.
+func redactErrors(errs command.Errors) command.Errors {
+	redactedErrs := make(command.Errors, len(errs))
+	for i, err := range errs {
+		redactedErrs[i] = redactedError{err}
+	}
+	return redactedErrs
+}
+
+// redactWriter writes to writer, with secrets redacted.
+type redactWriter struct {
+	writer io.Writer
+}
+
+// Write writes p to the writer, with secrets redacted. The length of p is returned, so that
+// callers aren't confused by the length changing.
+func (r redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.writer, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// RedactWriter returns a writer that writes to writer, with secrets redacted. It is useful for
+// logs (see log.SetOutput).
+func RedactWriter(writer io.Writer) io.Writer {
+	return redactWriter{writer}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/service/demoservice"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"secrets/key.txt": "file-secret\n"})
+	os.Setenv("BOBY_TEST_SECRET", "env-secret")
+	defer os.Unsetenv("BOBY_TEST_SECRET")
+
+	tests := map[string]string{
+		"key=${BOBY_TEST_SECRET}":                    "key=env-secret",
+		"${file:secrets/key.txt}":                    "file-secret",
+		"$${BOBY_TEST_SECRET} costs $5":              "${BOBY_TEST_SECRET} costs $5",
+		"no secrets {{.word}}":                       "no secrets {{.word}}",
+		"${BOBY_TEST_SECRET}${file:secrets/key.txt}": "env-secretfile-secret",
+	}
+
+	for text, expected := range tests {
+		resolved, err := ResolveSecrets(text, dir)
+		if err != nil || resolved != expected {
+			t.Errorf("%q was resolved as %q (%v), expected %q", text, resolved, err, expected)
+		}
+	}
+
+	if _, err := ResolveSecrets("${BOBY_TEST_MISSING}", dir); err == nil {
+		t.Errorf("A missing environment variable should be an error")
+	}
+
+	if _, err := ResolveSecrets("${file:missing.txt}", dir); err == nil {
+		t.Errorf("A missing file should be an error")
+	}
+
+	if redactedText := Redact("token env-secret and file-secret"); redactedText != "token [REDACTED] and [REDACTED]" {
+		t.Errorf("Secrets should be redacted: %s", redactedText)
+	}
+}
+
+func TestSecretsInCommands(t *testing.T) {
+	os.Setenv("BOBY_TEST_API_KEY", "api-key-value")
+	defer os.Unsetenv("BOBY_TEST_API_KEY")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+			{"Type": "json", "Trigger": "j", "URL": "https://example.com/?key=${BOBY_TEST_API_KEY}"},
+			{"Type": "json", "Trigger": "bad", "URL": "https://example.com/%s?key=${BOBY_TEST_API_KEY}"},
+			{"Type": "json", "Trigger": "missing", "URL": "https://example.com/?key=${BOBY_TEST_MISSING}"}
+		]`,
+	})
+
+	commands, err := configuredBot(dir)
+	if strings.Join(triggers(commands), ",") != "j" {
+		t.Errorf("Unexpected commands: %v", triggers(commands))
+	}
+
+	if err == nil || strings.Contains(err.Error(), "api-key-value") {
+		t.Errorf("Secrets should be redacted from problems: %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "key=[REDACTED]") || !strings.Contains(err.Error(), "BOBY_TEST_MISSING") {
+		t.Errorf("Problems should still be described: %v", err)
+	}
+}
+
+func TestSecretsInMessages(t *testing.T) {
+	os.Setenv("BOBY_TEST_QUERY_KEY", "query-key-value")
+	defer os.Unsetenv("BOBY_TEST_QUERY_KEY")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "<h1>Heading</h1><p>Paragraph</p>")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[{
+			"Type": "goquery",
+			"Trigger": "g",
+			"URL": "` + server.URL + `/?key=${BOBY_TEST_QUERY_KEY}",
+			"TitleSelector": {"Template": "%s", "Selectors": ["h1"]},
+			"ReplySelector": {"Template": "%s", "Selectors": ["p"]}
+		}]`,
+	})
+
+	commands, err := configuredBot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	demoSender := demoservice.DemoSender{}
+	conversation := service.Conversation{ServiceID: demoSender.ID(), ConversationID: "0"}
+	user := service.User{Name: "Test_User", ServiceID: demoSender.ID()}
+	commands[len(commands)-1].Exec(conversation, user, []interface{}{}, nil, demoSender.SendMessage)
+
+	message, _ := demoSender.PopMessage()
+	if message.Description != "Paragraph" {
+		t.Fatalf("Unexpected message: %v", message)
+	}
+
+	if text := fmt.Sprint(message); strings.Contains(text, "query-key-value") || !strings.Contains(text, "key=[REDACTED]") {
+		t.Errorf("Secrets should be redacted from messages: %s", text)
+	}
+}
+
+func TestSecretsErrorLine(t *testing.T) {
+	os.Setenv("BOBY_TEST_LONG_KEY", strings.Repeat("k", 200))
+	defer os.Unsetenv("BOBY_TEST_LONG_KEY")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		commandsFile: `[
+	{"Type": "json", "Trigger": "a"},
+	{
+		"Type": "json",
+		"URL": "https://example.com/?key=${BOBY_TEST_LONG_KEY}",
+		"Trigger": 5
+	}
+]`,
+	})
+
+	_, err := configuredBot(dir)
+	if err == nil || !strings.Contains(err.Error(), commandsFile+":3:") {
+		t.Errorf("Error should include the line the entry starts on: %v", err)
+	}
+}
+
+func TestSecretsInUnmarshal(t *testing.T) {
+	os.Setenv("BOBY_TEST_TOKEN", "discord-token")
+	defer os.Unsetenv("BOBY_TEST_TOKEN")
+
+	var parsed struct{ Token string }
+	filepath := path.Join(t.TempDir(), "config.yaml")
+	if err := Unmarshal(filepath, []byte("Token: ${BOBY_TEST_TOKEN}\n"), &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Token != "discord-token" {
+		t.Errorf("Unexpected token: %s", parsed.Token)
+	}
+
+	var buffer bytes.Buffer
+	logger := log.New(RedactWriter(&buffer), "", 0)
+	logger.Printf("Logging in with %s", parsed.Token)
+	if buffer.String() != "Logging in with [REDACTED]\n" {
+		t.Errorf("Secrets should be redacted from logs: %q", buffer.String())
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+)
+
+// fileState is used to notice when a file has changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot returns the state of every configuration file in dir and its commands directory.
+// The service config file isn't included, as it isn't reloaded.
+func snapshot(dir string) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, folder := range []string{dir, path.Join(dir, commandsDir)} {
+		files, err := ioutil.ReadDir(folder)
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			name := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+			if folder == dir && name == ServiceConfigName {
+				continue
+			}
+
+			if !file.IsDir() && isConfigFile(file.Name()) {
+				states[path.Join(folder, file.Name())] = fileState{
+					modTime: file.ModTime(),
+					size:    file.Size(),
+				}
+			}
+		}
+	}
+	return states
+}
+
+// changed returns true if two snapshots are different.
+func changed(before map[string]fileState, after map[string]fileState) bool {
+	if len(before) != len(after) {
+		return true
+	}
+
+	for filepath, state := range before {
+		if afterState, ok := after[filepath]; !ok || afterState != state {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch calls onChange whenever a configuration file in dir (or its commands directory) is
+// added, removed or modified. Files are checked every interval, until stop is closed.
+// This blocks, so it is usually called as a goroutine.
+func Watch(dir string, interval time.Duration, stop <-chan struct{}, onChange func()) {
+	before := snapshot(dir)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			after := snapshot(dir)
+			if changed(before, after) {
+				before = after
+				onChange()
+			}
+		}
+	}
+}
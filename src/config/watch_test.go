@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{commandsFile: `[]`})
+
+	changes := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	defer close(stop)
+	go Watch(dir, time.Millisecond, stop, func() { changes <- struct{}{} })
+
+	// Allow the initial snapshot to be taken.
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-changes:
+		t.Fatalf("No change has been made")
+	default:
+	}
+
+	writeFiles(t, dir, map[string]string{path.Join(commandsDir, "new.yaml"): `[]`})
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatalf("Adding a file should be noticed")
+	}
+
+	writeFiles(t, dir, map[string]string{"ignored.txt": `[]`, ServiceConfigName + ".json": `{}`})
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-changes:
+		t.Errorf("Files that aren't reloaded should be ignored")
+	default:
+	}
+
+	os.Remove(path.Join(dir, commandsFile))
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatalf("Removing a file should be noticed")
+	}
+}
+
+func TestChanged(t *testing.T) {
+	now := time.Now()
+	before := map[string]fileState{"a": {modTime: now, size: 1}}
+
+	if changed(before, map[string]fileState{"a": {modTime: now, size: 1}}) {
+		t.Errorf("Identical snapshots haven't changed")
+	}
+
+	if !changed(before, map[string]fileState{"a": {modTime: now, size: 2}}) {
+		t.Errorf("A change in size is a change")
+	}
+
+	if !changed(before, map[string]fileState{"b": {modTime: now, size: 1}}) {
+		t.Errorf("A renamed file is a change")
+	}
+
+	if !changed(before, map[string]fileState{}) {
+		t.Errorf("A removed file is a change")
+	}
+}
@@ -5,25 +5,36 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/gob"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"path"
 
 	"log"
 	"syscall"
+	"time"
 
+	"github.com/BKrajancic/boby/m/v2/src/command"
 	"github.com/BKrajancic/boby/m/v2/src/config"
 	"github.com/BKrajancic/boby/m/v2/src/service/discordservice"
 	"github.com/BKrajancic/boby/m/v2/src/storage"
 )
 
+// reloadInterval is how often the configuration folder is checked for changes.
+const reloadInterval = 5 * time.Second
+
 func main() {
+	if len(os.Args) == 3 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2]))
+	}
+
 	f, err := os.OpenFile("logging.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
 		log.Fatalf("error opening file: %v", err)
 	}
 	defer f.Close()
-	log.SetOutput(f)
+	log.SetOutput(config.RedactWriter(f))
 
 	exampleDir := "example"
 	if len(os.Args) == 1 {
@@ -44,13 +55,19 @@ func main() {
 	prefix := "!"
 	storage.SetDefaultGuildValue("prefix", prefix)
 
+	cachePath := path.Join(folder, "cache.gob")
+	if err := loadCache(cachePath); err != nil {
+		log.Printf("Unable to load cached responses: %s", err)
+	}
+	defer saveCache(cachePath)
+
 	commands, err := config.ConfiguredBot(folder, &storage)
 	if err != nil {
 		config.MakeExampleDir(exampleDir)
 		log.Panicf("An error occurred when loading the configuration files: %s", err)
 	}
 
-	discordConfig := path.Join(folder, "config.json")
+	discordConfig := config.FindFile(folder, config.ServiceConfigName)
 	discordSubject, _, discord, err := discordservice.NewDiscords(discordConfig)
 	if err != nil {
 		log.Panicf("An error occurred when loading discord: %s", err)
@@ -66,7 +83,21 @@ func main() {
 	discordSubject.Load()
 	discordSubject.UnloadUselessCommands()
 
-	discord.UpdateGameStatus(0, "/help")
+	discordSubject.SetReloader(func() ([]command.Command, error) {
+		return config.ConfiguredBot(folder, &storage)
+	})
+
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	go config.Watch(folder, reloadInterval, stopWatching, func() {
+		if count, err := discordSubject.Reload(); err != nil {
+			log.Printf("Configuration changed, but could not be reloaded: %s", err)
+		} else {
+			log.Printf("Configuration changed, reloaded %d commands", count)
+		}
+	})
+
+	discordSubject.StartPresence()
 	log.Println("bot has loaded")
 
 	sc := make(chan os.Signal, 1)
@@ -74,6 +105,61 @@ func main() {
 	<-sc
 }
 
+// validate prints every problem with the configuration files in folder, returning an
+// exit code that is non-zero if there are any problems.
+func validate(folder string) int {
+	problems := config.Validate(folder)
+
+	discordConfig := config.FindFile(folder, config.ServiceConfigName)
+	if contents, err := ioutil.ReadFile(discordConfig); err == nil {
+		var parsed discordservice.DiscordConfig
+		if err := config.Unmarshal(discordConfig, contents, &parsed); err != nil {
+			problems = append(problems, err)
+		} else if err := parsed.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", discordConfig, err))
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, config.Redact(problem.Error()))
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problems were found.\n", len(problems))
+		return 1
+	}
+
+	fmt.Println("No problems were found.")
+	return 0
+}
+
+// loadCache loads responses that were cached before the bot last stopped, if there are any.
+func loadCache(filepath string) error {
+	file, err := os.Open(filepath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return command.DefaultCache.Load(file)
+}
+
+// saveCache saves cached responses, so that they can be used after the bot restarts.
+func saveCache(filepath string) {
+	file, err := os.Create(filepath)
+	if err != nil {
+		log.Printf("Unable to save cached responses: %s", err)
+		return
+	}
+	defer file.Close()
+
+	if err := command.DefaultCache.Save(file); err != nil {
+		log.Printf("Unable to save cached responses: %s", err)
+	}
+}
+
 // loadGobStorage loads a file used for storage.
 // If the file doesn't exist, a file is created and used.
 func loadGobStorage(filepath string) (storage.Storage, error) {
@@ -6,11 +6,13 @@ package service
 // Examples include:
 // 	   1. A conversation between a bot and a user.
 // 	   2. A chatroom with many human and bot users.
+// 	   3. A thread that branches off of a chatroom.
 type Conversation struct {
 	ServiceID      string
 	ConversationID string
 	GuildID        string
 	Admin          bool
+	ParentID       string // If this conversation is a thread, ParentID is the ConversationID it belongs to.
 }
 
 // Guild will convert a conversation to a Guild.
@@ -20,3 +22,30 @@ func (c Conversation) Guild() Guild {
 		GuildID:   c.GuildID,
 	}
 }
+
+// IsThread returns true if this conversation is a thread of another conversation.
+func (c Conversation) IsThread() bool {
+	return c.ParentID != ""
+}
+
+// Parent returns the conversation that this conversation is a thread of.
+// If this conversation isn't a thread, it is returned unchanged.
+func (c Conversation) Parent() Conversation {
+	if !c.IsThread() {
+		return c
+	}
+
+	parent := c
+	parent.ConversationID = c.ParentID
+	parent.ParentID = ""
+	return parent
+}
+
+// Thread returns a conversation for a thread with ID threadID, that belongs to this
+// conversation.
+func (c Conversation) Thread(threadID string) Conversation {
+	thread := c
+	thread.ParentID = c.ConversationID
+	thread.ConversationID = threadID
+	return thread
+}
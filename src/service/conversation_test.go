@@ -16,3 +16,31 @@ func TestConversationToGuild(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestConversationThread(t *testing.T) {
+	conversation := Conversation{ServiceID: "100", ConversationID: "1", GuildID: "100"}
+	if conversation.IsThread() {
+		t.Errorf("A conversation without a parent isn't a thread")
+	}
+
+	thread := conversation.Thread("2")
+	if !thread.IsThread() {
+		t.Errorf("A thread should know it is a thread")
+	}
+
+	if thread.ConversationID != "2" || thread.ParentID != "1" {
+		t.Errorf("Thread has wrong IDs")
+	}
+
+	if thread.Guild() != conversation.Guild() {
+		t.Errorf("Thread should belong to the same guild")
+	}
+
+	if thread.Parent() != conversation {
+		t.Errorf("Parent of a thread should be the original conversation")
+	}
+
+	if conversation.Parent() != conversation {
+		t.Errorf("Parent of a non-thread should be itself")
+	}
+}
@@ -1,12 +1,12 @@
 package discordservice
 
 import (
-	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"log"
 	"os"
 
+	"github.com/BKrajancic/boby/m/v2/src/config"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -15,17 +15,29 @@ const ServiceID = "Discord"
 
 // DiscordConfig has data required for discord to work (e.g. Token).
 type DiscordConfig struct {
-	Token string
+	Token    string
+	Owners   []string       // IDs of users who own this bot, and can use owner commands.
+	Presence PresenceConfig // What is shown as the bot's status.
 }
 
-// getConfig reads a local json file, and returns a configuration object to load discord.
+// Validate returns a problem with the config, such as an invalid presence.
+// A presence without messages isn't used, so it isn't validated.
+func (d DiscordConfig) Validate() error {
+	if len(d.Presence.Messages) > 0 {
+		return d.Presence.Validate()
+	}
+	return nil
+}
+
+// getConfig reads a local json (or yaml) file, and returns a configuration object to load discord.
+// The Token can reference a secret, such as "${DISCORD_TOKEN}" (see config.ResolveSecrets).
 // If the file doesn't exist at filepath, an error is returned and a message is printed.
 func getConfig(filepath string) (*DiscordConfig, error) {
 	const tokenDefault = "TOKEN"
 
 	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		example := &DiscordConfig{Token: tokenDefault}
-		bytes, err := json.Marshal(example)
+		example := &DiscordConfig{Token: tokenDefault, Presence: defaultPresenceConfig()}
+		bytes, err := config.Marshal(filepath, example)
 		if err != nil {
 			log.Printf("Unable to create an example json (haven't even tried creating a file yet).")
 			return nil, err
@@ -53,25 +65,34 @@ func getConfig(filepath string) (*DiscordConfig, error) {
 		return nil, err
 	}
 
-	var config DiscordConfig
-	json.Unmarshal(bytes, &config)
-	if config.Token == tokenDefault {
+	var discordConfig DiscordConfig
+	if err := config.Unmarshal(filepath, bytes, &discordConfig); err != nil {
+		log.Printf("Unable to parse file: %s", err)
+		return nil, err
+	}
+
+	if err := discordConfig.Validate(); err != nil {
+		log.Printf("Invalid presence in %s: %s", filepath, err)
+		return nil, err
+	}
+
+	if discordConfig.Token == tokenDefault {
 		log.Printf("Demo JSON has not been updated to have a valid token! A user should edit: %s", filepath)
 		return nil, errors.New("default file used")
 	}
 
-	return &config, nil
+	return &discordConfig, nil
 }
 
 // NewDiscords Creates subject and sender service adapters for discord.
 // Discord is loaded using information from a file
 func NewDiscords(filepath string) (*DiscordSubject, *DiscordSender, *discordgo.Session, error) {
-	config, err := getConfig(filepath) // Get token
+	discordConfig, err := getConfig(filepath) // Get token
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	discord, err := discordgo.New("Bot " + config.Token)
+	discord, err := discordgo.New("Bot " + discordConfig.Token)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -82,7 +103,10 @@ func NewDiscords(filepath string) (*DiscordSubject, *DiscordSender, *discordgo.S
 	}
 
 	discordSubject := DiscordSubject{
-		discord: discord,
+		discord:  discord,
+		owners:   discordConfig.Owners,
+		presence: newPresence(discordConfig.Presence),
+		closed:   make(chan struct{}),
 	}
 
 	// Register the messageCreate func as a callback for MessageCreate events.
@@ -0,0 +1,193 @@
+package discordservice
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// activityTypeCompeting is an activity type that isn't yet included in discordgo.
+const activityTypeCompeting discordgo.ActivityType = 5
+
+// activityTypes maps the names of activity types used in configuration to discord's activity types.
+var activityTypes = map[string]discordgo.ActivityType{
+	"playing":   discordgo.ActivityTypeGame,
+	"streaming": discordgo.ActivityTypeStreaming,
+	"listening": discordgo.ActivityTypeListening,
+	"watching":  discordgo.ActivityTypeWatching,
+	"competing": activityTypeCompeting,
+}
+
+// activityTypeNames describes the names of activity types, to show when an unknown one is used.
+const activityTypeNames = "Playing, Streaming, Listening, Watching or Competing"
+
+// PresenceConfig describes what is shown as the bot's status.
+//
+// Messages can include the placeholders {guilds} and {commands}, which are replaced with
+// the number of guilds the bot is in, and the number of commands the bot has.
+type PresenceConfig struct {
+	ActivityType string   // One of "Playing", "Streaming", "Listening", "Watching" or "Competing".
+	Messages     []string // Messages to show, one at a time.
+	Interval     int      // Seconds between showing each message (and refreshing placeholders). If 0, only the first message is shown.
+	URL          string   // A url to use when ActivityType is "Streaming".
+}
+
+// Validate returns a problem if the activity type is unknown or the interval is negative.
+func (p PresenceConfig) Validate() error {
+	if _, ok := activityTypes[strings.ToLower(p.ActivityType)]; !ok {
+		return fmt.Errorf("unknown Presence.ActivityType \"%s\", expected %s", p.ActivityType, activityTypeNames)
+	}
+
+	if p.Interval < 0 {
+		return fmt.Errorf("the Presence.Interval can't be negative")
+	}
+	return nil
+}
+
+// defaultPresenceConfig returns the presence used when none is configured.
+func defaultPresenceConfig() PresenceConfig {
+	return PresenceConfig{
+		ActivityType: "Playing",
+		Messages:     []string{"/help"},
+		Interval:     0,
+	}
+}
+
+// presence rotates the bot's status through the messages of a PresenceConfig.
+type presence struct {
+	config PresenceConfig
+	index  int
+	update chan struct{}
+	mutex  sync.Mutex // Lock when accessing config or index.
+}
+
+// newPresence returns a presence using config. If config has no messages, a default is used.
+func newPresence(config PresenceConfig) *presence {
+	if len(config.Messages) == 0 {
+		config = defaultPresenceConfig()
+	}
+
+	return &presence{config: config, update: make(chan struct{}, 1)}
+}
+
+// setConfig replaces the config of p, and shows its first message as soon as possible.
+func (p *presence) setConfig(config PresenceConfig) {
+	p.mutex.Lock()
+	p.config = config
+	p.index = 0
+	p.mutex.Unlock()
+
+	select {
+	case p.update <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the activity to show now, then moves on to the next message.
+func (p *presence) next(guilds int, commands int) discordgo.Activity {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	replacer := strings.NewReplacer(
+		"{guilds}", strconv.Itoa(guilds),
+		"{commands}", strconv.Itoa(commands),
+	)
+
+	activity := discordgo.Activity{
+		Name: replacer.Replace(p.config.Messages[p.index%len(p.config.Messages)]),
+		Type: activityTypes[strings.ToLower(p.config.ActivityType)],
+	}
+
+	if activity.Type == discordgo.ActivityTypeStreaming {
+		activity.URL = p.config.URL
+	}
+
+	p.index = (p.index + 1) % len(p.config.Messages)
+	return activity
+}
+
+// interval returns how long to wait between messages. If 0, messages aren't rotated.
+func (p *presence) interval() time.Duration {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return time.Duration(p.config.Interval) * time.Second
+}
+
+// updatePresence shows the next message of the bot's presence.
+func (d *DiscordSubject) updatePresence() {
+	activity := d.presence.next(len(d.discord.State.Guilds), len(d.commands()))
+	err := d.discord.UpdateStatusComplex(discordgo.UpdateStatusData{
+		Activities: []*discordgo.Activity{&activity},
+	})
+
+	if err != nil {
+		log.Printf("Unable to update presence: %s", err)
+	}
+}
+
+// StartPresence shows the bot's presence, rotating through messages until Close is called.
+func (d *DiscordSubject) StartPresence() {
+	d.updatePresence()
+
+	go func() {
+		for {
+			var tick <-chan time.Time
+			if interval := d.presence.interval(); interval > 0 {
+				tick = time.After(interval)
+			}
+
+			select {
+			case <-tick:
+			case <-d.presence.update:
+			case <-d.closed:
+				return
+			}
+			d.updatePresence()
+		}
+	}()
+}
+
+// setPresenceExec changes the bot's presence. Only owners are able to use this.
+// The first word is the activity type, the rest are messages separated by '|'.
+func (d *DiscordSubject) setPresenceExec(conversation service.Conversation, user service.User, msg []interface{}, _ *storage.Storage, sink func(service.Conversation, service.Message)) {
+	if !d.isOwner(user.Name) {
+		return
+	}
+
+	activityType := msg[0].(string)
+	if _, ok := activityTypes[strings.ToLower(activityType)]; !ok {
+		sink(conversation, service.Message{
+			Description: fmt.Sprintf("'%s' is not an activity type, expected %s.", activityType, activityTypeNames),
+		})
+		return
+	}
+
+	messages := []string{}
+	for _, message := range strings.Split(msg[1].(string), "|") {
+		if message = strings.TrimSpace(message); message != "" {
+			messages = append(messages, message)
+		}
+	}
+
+	if len(messages) == 0 {
+		sink(conversation, service.Message{Description: "At least one message is required."})
+		return
+	}
+
+	d.presence.mutex.Lock()
+	config := d.presence.config
+	d.presence.mutex.Unlock()
+
+	config.ActivityType = activityType
+	config.Messages = messages
+	d.presence.setConfig(config)
+
+	sink(conversation, service.Message{Description: "Presence has been set."})
+}
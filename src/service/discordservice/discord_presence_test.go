@@ -0,0 +1,75 @@
+package discordservice
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestPresenceRotates(t *testing.T) {
+	p := newPresence(PresenceConfig{
+		ActivityType: "Watching",
+		Messages:     []string{"{guilds} servers", "{commands} commands"},
+		Interval:     60,
+	})
+
+	expected := []string{"3 servers", "10 commands", "3 servers"}
+	for _, name := range expected {
+		if activity := p.next(3, 10); activity.Name != name || activity.Type != discordgo.ActivityTypeWatching {
+			t.Errorf("Expected %q, got %v", name, activity)
+		}
+	}
+
+	if p.interval().Seconds() != 60 {
+		t.Errorf("Unexpected interval: %s", p.interval())
+	}
+}
+
+func TestPresenceSetConfig(t *testing.T) {
+	p := newPresence(PresenceConfig{ActivityType: "Playing", Messages: []string{"a", "b"}})
+	p.next(0, 0)
+
+	p.setConfig(PresenceConfig{ActivityType: "streaming", Messages: []string{"c", "d"}, URL: "https://example.com"})
+	select {
+	case <-p.update:
+	default:
+		t.Errorf("Setting a config should update the presence")
+	}
+
+	activity := p.next(0, 0)
+	if activity.Name != "c" || activity.Type != discordgo.ActivityTypeStreaming || activity.URL != "https://example.com" {
+		t.Errorf("A new config should start from its first message: %v", activity)
+	}
+}
+
+func TestPresenceDefault(t *testing.T) {
+	p := newPresence(PresenceConfig{})
+	if activity := p.next(0, 0); activity.Name != "/help" || p.interval() != 0 {
+		t.Errorf("Without messages, the default presence should be used: %v", activity)
+	}
+}
+
+func TestPresenceValidate(t *testing.T) {
+	if err := defaultPresenceConfig().Validate(); err != nil {
+		t.Errorf("The default presence should be valid: %s", err)
+	}
+
+	tests := []PresenceConfig{
+		{ActivityType: "Dancing", Messages: []string{"a"}},
+		{ActivityType: "Playing", Messages: []string{"a"}, Interval: -1},
+	}
+
+	for _, test := range tests {
+		if err := test.Validate(); err == nil {
+			t.Errorf("Presence %v should be invalid", test)
+		}
+
+		if err := (DiscordConfig{Presence: test}).Validate(); err == nil {
+			t.Errorf("A config with presence %v should be invalid", test)
+		}
+	}
+
+	if err := (DiscordConfig{Presence: PresenceConfig{ActivityType: "Dancing"}}).Validate(); err != nil {
+		t.Errorf("A presence without messages isn't used, so it shouldn't be a problem: %s", err)
+	}
+}
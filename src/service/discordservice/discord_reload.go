@@ -0,0 +1,105 @@
+package discordservice
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/BKrajancic/boby/m/v2/src/command"
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// SetCommands replaces every command added using Register (or SetCommands) with commands.
+// Only slash commands that have been added, changed or removed are updated.
+func (d *DiscordSubject) SetCommands(commands []command.Command) {
+	d.mutex.Lock()
+	previous := d.observers
+	d.observers = commands
+	builtins := d.builtins
+	d.mutex.Unlock()
+
+	changed, removed := changedCommands(previous, commands, builtins)
+	if len(changed) == 0 && len(removed) == 0 {
+		return
+	}
+
+	for _, guild := range d.discord.State.Guilds {
+		d.syncGuildCommands(guild.ID, changed, removed)
+	}
+	d.syncGuildCommands("", changed, removed) // Global slash commands.
+}
+
+// changedCommands compares two sets of commands, returning the commands of current that
+// are new or have a different slash command, and the triggers of commands that were
+// removed. Triggers of builtins are never considered removed.
+func changedCommands(previous []command.Command, current []command.Command, builtins []command.Command) (changed []command.Command, removed []string) {
+	previousByTrigger := make(map[string]discordgo.ApplicationCommand)
+	for _, cmd := range previous {
+		previousByTrigger[cmd.Trigger] = commandToApplicationCommand(cmd)
+	}
+
+	currentTriggers := make(map[string]bool)
+	for _, cmd := range builtins {
+		currentTriggers[cmd.Trigger] = true
+	}
+
+	for _, cmd := range current {
+		currentTriggers[cmd.Trigger] = true
+		previousCmd, ok := previousByTrigger[cmd.Trigger]
+		if !ok || !reflect.DeepEqual(previousCmd, commandToApplicationCommand(cmd)) {
+			changed = append(changed, cmd)
+		}
+	}
+
+	for _, cmd := range previous {
+		if !currentTriggers[cmd.Trigger] {
+			removed = append(removed, cmd.Trigger)
+		}
+	}
+
+	return changed, removed
+}
+
+// SetReloader sets what is used by Reload to get a new set of commands.
+func (d *DiscordSubject) SetReloader(reloader func() ([]command.Command, error)) {
+	d.reloader = reloader
+}
+
+// Reload replaces this bot's commands with those from the reloader set by SetReloader.
+// If the reloader returns an error, the current commands are kept. Reloads happen one at a
+// time, so the commands that are set are from the last reload to finish.
+func (d *DiscordSubject) Reload() (int, error) {
+	if d.reloader == nil {
+		return 0, fmt.Errorf("reloading has not been set up")
+	}
+
+	d.reloading.Lock()
+	defer d.reloading.Unlock()
+
+	commands, err := d.reloader()
+	if err != nil {
+		return 0, err
+	}
+
+	d.SetCommands(commands)
+	return len(commands), nil
+}
+
+// reloadExec reloads this bot's commands. Only owners are able to use this.
+func (d *DiscordSubject) reloadExec(conversation service.Conversation, user service.User, _ []interface{}, _ *storage.Storage, sink func(service.Conversation, service.Message)) {
+	if !d.isOwner(user.Name) {
+		return
+	}
+
+	count, err := d.Reload()
+	if err != nil {
+		sink(conversation, service.Message{
+			Title:       "Unable to reload",
+			Description: err.Error(),
+		})
+		return
+	}
+
+	sink(conversation, service.Message{Description: fmt.Sprintf("Reloaded %d commands.", count)})
+}
@@ -0,0 +1,128 @@
+package discordservice
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/command"
+	"github.com/google/go-cmp/cmp"
+)
+
+// triggers returns the trigger of each of commands.
+func triggers(commands []command.Command) []string {
+	result := []string{}
+	for _, cmd := range commands {
+		result = append(result, cmd.Trigger)
+	}
+	return result
+}
+
+func TestChangedCommands(t *testing.T) {
+	previous := []command.Command{
+		{Trigger: "same", Help: "Unchanged."},
+		{Trigger: "help", Help: "Changed."},
+		{Trigger: "removed", Help: "Removed."},
+		{Trigger: "reload", Help: "Now a builtin."},
+	}
+	current := []command.Command{
+		{Trigger: "same", Help: "Unchanged."},
+		{Trigger: "help", Help: "Different."},
+		{Trigger: "added", Help: "Added."},
+	}
+	builtins := []command.Command{{Trigger: "reload"}}
+
+	changed, removed := changedCommands(previous, current, builtins)
+	if diff := cmp.Diff([]string{"help", "added"}, triggers(changed)); diff != "" {
+		t.Errorf("Unexpected changed commands: %s", diff)
+	}
+
+	if diff := cmp.Diff([]string{"removed"}, removed); diff != "" {
+		t.Errorf("Unexpected removed commands: %s", diff)
+	}
+}
+
+func TestChangedCommandsParameters(t *testing.T) {
+	previous := []command.Command{{Trigger: "define", Parameters: []command.Parameter{{Name: "word", Type: "string"}}}}
+	current := []command.Command{{Trigger: "define", Parameters: []command.Parameter{{Name: "word", Type: "int"}}}}
+
+	if changed, _ := changedCommands(previous, current, nil); len(changed) != 1 {
+		t.Errorf("A command with different parameters should be changed")
+	}
+
+	if changed, removed := changedCommands(current, current, nil); len(changed) != 0 || len(removed) != 0 {
+		t.Errorf("Identical commands shouldn't be changed")
+	}
+}
+
+func TestSetCommandsUnchanged(t *testing.T) {
+	commands := []command.Command{{Trigger: "same", Help: "Unchanged."}}
+	d := DiscordSubject{observers: commands}
+
+	// No slash commands are changed, so discord isn't used.
+	replacement := []command.Command{{Trigger: "same", Help: "Unchanged."}}
+	d.SetCommands(replacement)
+	if diff := cmp.Diff(triggers(replacement), triggers(d.commands())); diff != "" {
+		t.Errorf("Commands should be replaced: %s", diff)
+	}
+}
+
+func TestReload(t *testing.T) {
+	commands := []command.Command{{Trigger: "same"}}
+	d := DiscordSubject{observers: commands}
+
+	if _, err := d.Reload(); err == nil {
+		t.Errorf("Reloading without a reloader should fail")
+	}
+
+	d.SetReloader(func() ([]command.Command, error) {
+		return []command.Command{{Trigger: "broken"}}, errors.New("invalid")
+	})
+	if _, err := d.Reload(); err == nil || len(d.commands()) != 1 || d.commands()[0].Trigger != "same" {
+		t.Errorf("Commands should be kept when reloading fails: %v", err)
+	}
+
+	d.SetReloader(func() ([]command.Command, error) {
+		return []command.Command{{Trigger: "same"}}, nil
+	})
+	if count, err := d.Reload(); err != nil || count != 1 {
+		t.Errorf("Reloading should succeed, got %d commands and %v", count, err)
+	}
+}
+
+func TestReloadOneAtATime(t *testing.T) {
+	d := DiscordSubject{observers: []command.Command{{Trigger: "same"}}}
+
+	var mutex sync.Mutex
+	active, most := 0, 0
+	d.SetReloader(func() ([]command.Command, error) {
+		mutex.Lock()
+		active++
+		if active > most {
+			most = active
+		}
+		mutex.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+		return []command.Command{{Trigger: "same"}}, nil
+	})
+
+	var wait sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			d.Reload()
+		}()
+	}
+	wait.Wait()
+
+	if most != 1 {
+		t.Errorf("Reloads should happen one at a time, but %d happened at once", most)
+	}
+}
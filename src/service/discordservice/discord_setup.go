@@ -0,0 +1,125 @@
+package discordservice
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/BKrajancic/boby/m/v2/src/command"
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/BKrajancic/boby/m/v2/src/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// onboardingWindow is how recently the bot must have joined a guild for it to send an
+// onboarding message. This avoids guilds that the bot was added to before onboarding
+// existed from receiving one.
+const onboardingWindow = 10 * time.Minute
+
+// requiredPermission is a permission the bot needs, with a name that users will understand.
+type requiredPermission struct {
+	Permission int64
+	Name       string
+}
+
+// requiredPermissions are the permissions the bot needs in a channel to work properly.
+var requiredPermissions = []requiredPermission{
+	{discordgo.PermissionViewChannel, "View Channel"},
+	{discordgo.PermissionSendMessages, "Send Messages"},
+	{discordgo.PermissionEmbedLinks, "Embed Links"},
+	{discordgo.PermissionReadMessageHistory, "Read Message History"},
+	{1 << 35, "Create Public Threads"},
+	{1 << 38, "Send Messages in Threads"},
+}
+
+// missingPermissions returns the names of permissions the bot doesn't have in a channel.
+func missingPermissions(s *discordgo.Session, channelID string) ([]string, error) {
+	permissions, err := s.State.UserChannelPermissions(s.State.User.ID, channelID)
+	if err != nil {
+		permissions, err = s.UserChannelPermissions(s.State.User.ID, channelID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	missing := []string{}
+	if permissions&discordgo.PermissionAdministrator == discordgo.PermissionAdministrator {
+		return missing, nil
+	}
+
+	for _, required := range requiredPermissions {
+		if permissions&required.Permission != required.Permission {
+			missing = append(missing, required.Name)
+		}
+	}
+	return missing, nil
+}
+
+// onboard sends an onboarding message to a guild's system channel, if the bot has only just
+// joined and the guild hasn't been onboarded before.
+func (d *DiscordSubject) onboard(s *discordgo.Session, discordGuild *discordgo.Guild) {
+	guild := service.Guild{ServiceID: d.ID(), GuildID: discordGuild.ID}
+	if onboarded, ok := (*d.storage).GetGuildValue(guild, command.OnboardedKey); ok && onboarded == true {
+		return
+	}
+	(*d.storage).SetGuildValue(guild, command.OnboardedKey, true)
+
+	if time.Since(discordGuild.JoinedAt) > onboardingWindow || discordGuild.SystemChannelID == "" {
+		return
+	}
+
+	prefix, ok := (*d.storage).GetGuildValue(guild, command.PrefixKey)
+	if !ok {
+		prefix = ""
+	}
+
+	embed := MsgToEmbed(command.OnboardingMessage(fmt.Sprintf("%v", prefix)))
+	if _, err := s.ChannelMessageSendEmbed(discordGuild.SystemChannelID, &embed); err != nil {
+		log.Printf("Unable to send onboarding message for guild '%s': %s", discordGuild.ID, err)
+	}
+}
+
+// setupExec reports missing permissions and the current settings of a guild.
+// Only admins are able to use this.
+func (d *DiscordSubject) setupExec(conversation service.Conversation, user service.User, _ []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+	if !conversation.Admin {
+		return
+	}
+
+	if conversation.GuildID == "" {
+		sink(conversation, service.Message{Description: "Setup can only be used in a server."})
+		return
+	}
+
+	channels := []string{conversation.ConversationID}
+	if discordGuild, err := d.discord.State.Guild(conversation.GuildID); err == nil {
+		if discordGuild.SystemChannelID != "" && discordGuild.SystemChannelID != conversation.ConversationID {
+			channels = append(channels, discordGuild.SystemChannelID)
+		}
+	}
+
+	fields := []service.MessageField{}
+	for _, channelID := range channels {
+		value := "None"
+		missing, err := missingPermissions(d.discord, channelID)
+		if err != nil {
+			value = "Unable to check permissions."
+		} else if len(missing) > 0 {
+			value = strings.Join(missing, ", ")
+		}
+
+		fields = append(fields, service.MessageField{
+			Field: fmt.Sprintf("Missing permissions in <#%s>", channelID),
+			Value: value,
+		})
+	}
+
+	fields = append(fields, command.GuildSettingsFields(conversation.Guild(), storage)...)
+
+	sink(conversation, service.Message{
+		Title:       "Setup",
+		Description: "The current state of this bot for this server.",
+		Fields:      fields,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/BKrajancic/boby/m/v2/src/command"
 	"github.com/BKrajancic/boby/m/v2/src/service"
@@ -16,8 +17,15 @@ import (
 // A DiscordSubject receives messages from discord, and passes events to its observers.
 type DiscordSubject struct {
 	discord   *discordgo.Session
-	observers []command.Command
+	observers []command.Command // Commands added using Register or SetCommands.
+	builtins  []command.Command // Commands that are added by Load.
+	mutex     sync.RWMutex      // Lock when accessing observers.
 	storage   *storage.Storage
+	owners    []string                          // IDs of users who can use owner commands.
+	presence  *presence                         // What is shown as the bot's status.
+	closed    chan struct{}                     // Closed once Close is called.
+	reloader  func() ([]command.Command, error) // Used by Reload to get new commands.
+	reloading sync.Mutex                        // Lock while reloading, so reloads happen one at a time.
 }
 
 // SetStorage sets an object to use for storage/retrieval purposes.
@@ -35,12 +43,31 @@ func (d *DiscordSubject) updateGuildCommandsForAll() {
 // updateGuildCommands will add the bot's commands as slash commands for a guild.
 // If no guildID is provided, the slash commands are registered globally.
 func (d *DiscordSubject) updateGuildCommands(guildID string) {
+	d.syncGuildCommands(guildID, d.commands(), nil)
+}
+
+// syncGuildCommands will add (or update) commands as slash commands for a guild, and
+// remove slash commands with a name in removed.
+// If no guildID is provided, the slash commands are registered globally.
+func (d *DiscordSubject) syncGuildCommands(guildID string, commands []command.Command, removed []string) {
 	appID := d.discord.State.User.ID
 	cmds, err := d.discord.ApplicationCommands(appID, guildID)
 	if err != nil {
 		log.Printf("Error with slash commands: %s", err)
 	}
-	for _, cmd := range d.observers {
+
+	for _, existingCmd := range cmds {
+		for _, name := range removed {
+			if existingCmd.Name == name {
+				err := d.discord.ApplicationCommandDelete(existingCmd.ApplicationID, guildID, existingCmd.ID)
+				if err != nil {
+					log.Printf("Error removing slash command for guild '%s': %s", guildID, err)
+				}
+			}
+		}
+	}
+
+	for _, cmd := range commands {
 		command := commandToApplicationCommand(cmd)
 		found := false
 		for _, existingCmd := range cmds {
@@ -73,6 +100,7 @@ func (d *DiscordSubject) updateGuildCommands(guildID string) {
 // guildCreate executes upon joining a guild.
 func (d *DiscordSubject) guildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
 	d.updateGuildCommands(event.Guild.ID)
+	d.onboard(s, event.Guild)
 }
 
 // Load prepares this object for usage.
@@ -80,14 +108,71 @@ func (d *DiscordSubject) Load() {
 	d.discord.AddHandler(d.guildCreate)
 	d.discord.AddHandler(d.onSlashCommand)
 
-	d.Register(
+	d.registerBuiltin(
 		command.Command{
-			Trigger: "help",
+			Trigger: command.HelpTrigger,
 			Help:    "Provides information on how to use the bot.",
 			Exec:    d.helpExec,
 		},
 	)
 
+	d.registerBuiltin(
+		command.Command{
+			Trigger: command.SetupTrigger,
+			Help:    "Check for missing permissions and show this server's settings. Only admins can use this.",
+			Exec:    d.setupExec,
+		},
+	)
+
+	for _, cmd := range command.CustomCommandsAdmin(d.commands) {
+		d.registerBuiltin(cmd)
+	}
+
+	d.registerBuiltin(
+		command.Command{
+			Trigger: command.SetPresenceTrigger,
+			Parameters: []command.Parameter{
+				{
+					Name:        "type",
+					Description: activityTypeNames + ".",
+					Type:        "string",
+				},
+				{
+					Name:        "messages",
+					Description: "Messages to rotate through, separated by '|'.",
+					Type:        "string",
+				},
+			},
+			Help:      "Change the bot's status. Only owners of the bot can use this.",
+			HelpInput: "[type] [message | message]",
+			Exec:      d.setPresenceExec,
+		},
+	)
+
+	d.registerBuiltin(
+		command.Command{
+			Trigger: command.ReloadTrigger,
+			Help:    "Reload the bot's configuration. Only owners of the bot can use this.",
+			Exec:    d.reloadExec,
+		},
+	)
+
+	d.registerBuiltin(
+		command.Command{
+			Trigger: command.PurgeCacheTrigger,
+			Parameters: []command.Parameter{
+				{
+					Name:        "command",
+					Description: "Command to purge cached responses of, or 'all'.",
+					Type:        "string",
+				},
+			},
+			Help:      "Purge cached responses, so that new results are retrieved. Only owners of the bot can use this.",
+			HelpInput: "[command or 'all']",
+			Exec:      d.purgeCacheExec,
+		},
+	)
+
 	d.updateGuildCommandsForAll()
 	d.updateGuildCommands("") // Global slash commands.
 }
@@ -102,7 +187,7 @@ func (d *DiscordSubject) UnloadUselessCommands() {
 
 	for _, cmd := range cmds {
 		found := false
-		for _, observer := range d.observers {
+		for _, observer := range d.commands() {
 			found = observer.Trigger == cmd.Name
 			if found {
 				break
@@ -153,9 +238,28 @@ func commandToApplicationCommand(cmd command.Command) discordgo.ApplicationComma
 
 // Register will add an observer that will handle discord messages being received.
 func (d *DiscordSubject) Register(cmd command.Command) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
 	d.observers = append(d.observers, cmd)
 }
 
+// registerBuiltin will add a command that isn't replaced by SetCommands.
+func (d *DiscordSubject) registerBuiltin(cmd command.Command) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.builtins = append(d.builtins, cmd)
+}
+
+// commands returns every command of this bot, including builtin commands.
+func (d *DiscordSubject) commands() []command.Command {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	commands := make([]command.Command, 0, len(d.observers)+len(d.builtins))
+	commands = append(commands, d.observers...)
+	return append(commands, d.builtins...)
+}
+
 // ID returns the discord service ID, this is the same for all DiscordSubject objects.
 func (*DiscordSubject) ID() string {
 	return ServiceID
@@ -163,9 +267,28 @@ func (*DiscordSubject) ID() string {
 
 // Close will safely close all objects that are managed by this object.
 func (d *DiscordSubject) Close() {
+	close(d.closed)
 	d.discord.Close()
 }
 
+// isOwner returns true if userID is an owner of this bot.
+func (d *DiscordSubject) isOwner(userID string) bool {
+	for _, owner := range d.owners {
+		if owner == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// purgeCacheExec purges cached responses. Only owners are able to use this, as the cache is
+// shared by every guild.
+func (d *DiscordSubject) purgeCacheExec(conversation service.Conversation, user service.User, msg []interface{}, storage *storage.Storage, sink func(service.Conversation, service.Message)) {
+	if d.isOwner(user.Name) {
+		command.PurgeCache(conversation, user, msg, storage, sink)
+	}
+}
+
 func (d *DiscordSubject) messageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
 	d.onMessage(s, m.Message)
 }
@@ -186,6 +309,7 @@ func (d *DiscordSubject) onSlashCommand(s *discordgo.Session, i *discordgo.Inter
 		ConversationID: i.ChannelID,
 		GuildID:        i.GuildID,
 		Admin:          d.isAdmin(s, i.Member.User.ID, i.GuildID, i.Member.Roles),
+		ParentID:       threadParentID(s, i.ChannelID),
 	}
 
 	user := service.User{
@@ -193,8 +317,9 @@ func (d *DiscordSubject) onSlashCommand(s *discordgo.Session, i *discordgo.Inter
 		ServiceID: d.ID(),
 	}
 	input := []interface{}{}
-	footerText := "Requested by " + i.Member.User.Username + ": /" + i.Data.Name
-	for _, val := range i.Data.Options {
+	data := i.ApplicationCommandData()
+	footerText := "Requested by " + i.Member.User.Username + ": /" + data.Name
+	for _, val := range data.Options {
 		input = append(input, val.Value)
 		footerText += " " + val.StringValue()
 	}
@@ -210,7 +335,7 @@ func (d *DiscordSubject) onSlashCommand(s *discordgo.Session, i *discordgo.Inter
 		if len(*embeds) == 1 {
 			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionApplicationCommandResponseData{
+				Data: &discordgo.InteractionResponseData{
 					Embeds: *embeds,
 				},
 			})
@@ -221,15 +346,59 @@ func (d *DiscordSubject) onSlashCommand(s *discordgo.Session, i *discordgo.Inter
 		}
 	}
 
-	target := i.Data.Name
-	for j := range d.observers {
-		if d.observers[j].Trigger == target {
-			d.observers[j].Exec(conversation, user, input, d.storage, sink)
+	target := data.Name
+	for _, observer := range d.commands() {
+		if observer.Trigger == target {
+			if observer.ReplyInThread && !conversation.IsThread() {
+				sink = d.slashThreadSink(s, i, footerText, sink)
+			}
+			observer.Exec(conversation, user, input, d.storage, sink)
 			break
 		}
 	}
 }
 
+// slashThreadSink returns a sink that sends messages to a thread, made from the response to
+// the interaction i. If the interaction can't be responded to, sink is used instead, and if a
+// thread can't be made, messages are sent to the interaction's channel.
+func (d *DiscordSubject) slashThreadSink(s *discordgo.Session, i *discordgo.InteractionCreate, footerText string, sink func(service.Conversation, service.Message)) func(service.Conversation, service.Message) {
+	responded := false
+	threadID := ""
+	return func(destination service.Conversation, msg service.Message) {
+		if !responded {
+			err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: footerText,
+				},
+			})
+			if err != nil {
+				log.Printf("Unable to respond to interaction '%s': %s", i.ID, err)
+				sink(destination, msg)
+				return
+			}
+			responded = true
+
+			response, err := s.InteractionResponse(d.discord.State.User.ID, i.Interaction)
+			if err != nil {
+				log.Printf("Unable to retrieve the response to interaction '%s': %s", i.ID, err)
+			} else {
+				threadID, _ = startThread(s, destination.ConversationID, response.ID, msg.Title)
+			}
+		}
+
+		if threadID != "" {
+			destination = destination.Thread(threadID)
+		}
+
+		embed := MsgToEmbed(msg)
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: footerText}
+		if _, err := s.ChannelMessageSendEmbed(destination.ConversationID, &embed); err != nil {
+			log.Printf("Unable to send a message to channel '%s': %s", destination.ConversationID, err)
+		}
+	}
+}
+
 func (d *DiscordSubject) onMessage(s *discordgo.Session, m *discordgo.Message) {
 	if m.Author == nil || m.Author.ID == s.State.User.ID {
 		return
@@ -245,6 +414,7 @@ func (d *DiscordSubject) onMessage(s *discordgo.Session, m *discordgo.Message) {
 		ConversationID: m.ChannelID,
 		GuildID:        m.GuildID,
 		Admin:          d.isAdmin(s, m.Author.ID, m.GuildID, memberRoles),
+		ParentID:       threadParentID(s, m.ChannelID),
 	}
 
 	user := service.User{
@@ -257,7 +427,7 @@ func (d *DiscordSubject) onMessage(s *discordgo.Session, m *discordgo.Message) {
 		for _, field := range msg.Fields {
 			value := field.Value
 			if field.URL != "" {
-				value += fmt.Sprintf("\nRead more at: %s", field.URL)
+				value += service.ReadMore + field.URL
 			}
 			fields = append(
 				fields,
@@ -270,7 +440,7 @@ func (d *DiscordSubject) onMessage(s *discordgo.Session, m *discordgo.Message) {
 
 		desc := msg.Description
 		if msg.URL != "" {
-			desc += fmt.Sprintf("\nRead more at: %s", msg.URL)
+			desc += service.ReadMore + msg.URL
 		}
 
 		embed := discordgo.MessageEmbed{
@@ -296,12 +466,14 @@ func (d *DiscordSubject) onMessage(s *discordgo.Session, m *discordgo.Message) {
 		return
 	}
 
-	for j := range d.observers {
-		trigger := fmt.Sprintf("%s%s", prefix, d.observers[j].Trigger)
+	handled := false
+	for _, observer := range d.commands() {
+		trigger := fmt.Sprintf("%s%s", prefix, observer.Trigger)
 		if trigger == target {
+			handled = true
 			parsers := parserDiscord()
 			parameters := []string{}
-			for _, parameter := range d.observers[j].Parameters {
+			for _, parameter := range observer.Parameters {
 				parameters = append(parameters, parameter.Type)
 			}
 
@@ -311,7 +483,29 @@ func (d *DiscordSubject) onMessage(s *discordgo.Session, m *discordgo.Message) {
 				return
 			}
 
-			d.observers[j].Exec(conversation, user, input, d.storage, sink)
+			if observer.ReplyInThread {
+				observer.Exec(conversation, user, input, d.storage, threadSink(s, m.ID, m.Content, sink))
+			} else {
+				observer.Exec(conversation, user, input, d.storage, sink)
+			}
+		}
+	}
+
+	// Configured commands take precedence over custom commands with the same trigger.
+	if handled {
+		return
+	}
+
+	for _, custom := range command.CustomCommands(conversation.Guild(), d.storage) {
+		if fmt.Sprintf("%s%s", prefix, custom.Trigger) == target {
+			input := []interface{}{}
+			for _, word := range inputSplit[1:] {
+				if word != "" {
+					input = append(input, word)
+				}
+			}
+			custom.Exec(conversation, user, input, d.storage, sink)
+			break
 		}
 	}
 }
@@ -382,7 +576,9 @@ func (d *DiscordSubject) helpExec(conversation service.Conversation, user servic
 		prefix = ""
 	}
 
-	for i, command := range d.observers {
+	// Custom commands of the guild are listed after the bot's commands.
+	commands := append(d.commands(), command.CustomCommands(conversation.Guild(), storage)...)
+	for i, command := range commands {
 		fields = append(fields, service.MessageField{
 			Field: fmt.Sprintf(
 				"%s. %s%s %s",
@@ -0,0 +1,82 @@
+package discordservice
+
+import (
+	"log"
+	"strings"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/bwmarrin/discordgo"
+)
+
+// threadNameLimit is the maximum number of characters discord allows for a thread name.
+const threadNameLimit = 100
+
+// threadArchiveMinutes is how long a thread made by this bot stays active without messages.
+const threadArchiveMinutes = 60
+
+// threadParentID returns the ID of the channel that channelID is a thread of.
+// If channelID isn't a thread (or can't be retrieved), an empty string is returned.
+func threadParentID(s *discordgo.Session, channelID string) string {
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		channel, err = s.Channel(channelID)
+		if err != nil {
+			return ""
+		}
+		s.State.ChannelAdd(channel)
+	}
+
+	if channel.IsThread() {
+		return channel.ParentID
+	}
+	return ""
+}
+
+// threadName turns text into a name that can be used for a thread.
+func threadName(text string) string {
+	name := strings.TrimSpace(strings.Split(text, "\n")[0])
+	if name == "" {
+		name = "Results"
+	}
+
+	if runes := []rune(name); len(runes) > threadNameLimit {
+		name = string(runes[:threadNameLimit])
+	}
+	return name
+}
+
+// startThread makes a new thread from the message messageID, and returns the thread's ID.
+func startThread(s *discordgo.Session, channelID string, messageID string, name string) (string, error) {
+	thread, err := s.MessageThreadStart(channelID, messageID, threadName(name), threadArchiveMinutes)
+	if err != nil {
+		log.Printf("Unable to start a thread from message '%s': %s", messageID, err)
+		return "", err
+	}
+	return thread.ID, nil
+}
+
+// threadSink wraps around sink so that messages are sent to a thread made from messageID.
+// The thread is only made once a message is sent, and is reused for later messages.
+// If conversation is already a thread, or a thread can't be made, sink is used unchanged.
+// A thread that can't be made isn't tried again, so later messages are also sent using sink.
+func threadSink(s *discordgo.Session, messageID string, name string, sink func(service.Conversation, service.Message)) func(service.Conversation, service.Message) {
+	threadID := ""
+	failed := false
+	return func(destination service.Conversation, msg service.Message) {
+		if destination.IsThread() || failed {
+			sink(destination, msg)
+			return
+		}
+
+		if threadID == "" {
+			id, err := startThread(s, destination.ConversationID, messageID, name)
+			if err != nil {
+				failed = true
+				sink(destination, msg)
+				return
+			}
+			threadID = id
+		}
+		sink(destination.Thread(threadID), msg)
+	}
+}
@@ -0,0 +1,48 @@
+package discordservice
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/BKrajancic/boby/m/v2/src/service"
+	"github.com/bwmarrin/discordgo"
+)
+
+// failingTransport fails every request, counting how many were made.
+type failingTransport struct {
+	requests int
+}
+
+// RoundTrip fails the request.
+func (f *failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	f.requests++
+	return nil, errors.New("unavailable")
+}
+
+func TestThreadSinkFailure(t *testing.T) {
+	s, err := discordgo.New("Bot token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &failingTransport{}
+	s.Client = &http.Client{Transport: transport}
+
+	received := []service.Conversation{}
+	sink := threadSink(s, "message", "Results", func(destination service.Conversation, msg service.Message) {
+		received = append(received, destination)
+	})
+
+	channel := service.Conversation{ServiceID: ServiceID, ConversationID: "channel"}
+	sink(channel, service.Message{Description: "First"})
+	sink(channel, service.Message{Description: "Second"})
+
+	if transport.requests != 1 {
+		t.Errorf("A thread that can't be made shouldn't be tried again, but %d requests were made", transport.requests)
+	}
+
+	if len(received) != 2 || received[0] != channel || received[1] != channel {
+		t.Errorf("Messages should be sent to the channel: %v", received)
+	}
+}
@@ -1,8 +1,6 @@
 package discordservice
 
 import (
-	"fmt"
-
 	"github.com/BKrajancic/boby/m/v2/src/service"
 	"github.com/bwmarrin/discordgo"
 )
@@ -13,7 +11,7 @@ func MsgToEmbed(msg service.Message) discordgo.MessageEmbed {
 	for _, field := range msg.Fields {
 		value := field.Value
 		if field.URL != "" {
-			value += fmt.Sprintf("\nRead more at: %s", field.URL)
+			value += service.ReadMore + field.URL
 		}
 		fields = append(
 			fields,
@@ -26,7 +24,7 @@ func MsgToEmbed(msg service.Message) discordgo.MessageEmbed {
 
 	desc := msg.Description
 	if msg.URL != "" {
-		desc += fmt.Sprintf("\nRead more at: %s", msg.URL)
+		desc += service.ReadMore + msg.URL
 	}
 
 	embed := discordgo.MessageEmbed{
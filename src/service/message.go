@@ -1,11 +1,14 @@
 package service
 
+import "unicode/utf8"
+
 // A Message is sent using a Sender.
 type Message struct {
 	URL         string
 	Title       string
 	Description string
 	Fields      []MessageField
+	Failed      bool // True if the message describes a failure to get a result, such as an error.
 }
 
 // A MessageField stores a field and value pair.
@@ -15,3 +18,24 @@ type MessageField struct {
 	URL    string
 	Inline bool
 }
+
+// ReadMore is written before the URL of a message or a field, which is shown after its text.
+const ReadMore = "\nRead more at: "
+
+// ValueLength returns how many characters the value of f is shown with, including its URL.
+func (f MessageField) ValueLength() int {
+	length := utf8.RuneCountInString(f.Value)
+	if f.URL != "" {
+		length += utf8.RuneCountInString(ReadMore + f.URL)
+	}
+	return length
+}
+
+// Limits of a message, which every service is able to show. These are Discord's limits for an
+// embed, which are the strictest of the services.
+const (
+	MaxFields       = 25   // The most fields of a message.
+	MaxFieldName    = 256  // The most characters of a field's name.
+	MaxFieldValue   = 1024 // The most characters of a field's value.
+	MaxMessageTotal = 6000 // The most characters of a message's title, description and fields.
+)
@@ -67,6 +67,14 @@ func (g *GobStorage) GetGuildValue(guild service.Guild, key string) (interface{}
 	return g.TempStorage.GetGuildValue(guild, key)
 }
 
+// GetGuildValues retrieves every value set for a Guild, including defaults that haven't
+// been overridden.
+func (g *GobStorage) GetGuildValues(guild service.Guild) map[string]interface{} {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.TempStorage.GetGuildValues(guild)
+}
+
 // SetGuildValue sets the value for key, for a Guild.
 func (g *GobStorage) SetGuildValue(guild service.Guild, key string, value interface{}) {
 	g.mutex.Lock()
@@ -75,6 +83,14 @@ func (g *GobStorage) SetGuildValue(guild service.Guild, key string, value interf
 	g.SaveToFile()
 }
 
+// UnsetGuildValue removes the value for key, for a Guild.
+func (g *GobStorage) UnsetGuildValue(guild service.Guild, key string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.TempStorage.UnsetGuildValue(guild, key)
+	g.SaveToFile()
+}
+
 // SetDefaultGuildValue sets the default value for key, for all Guilds.
 func (g *GobStorage) SetDefaultGuildValue(key string, value interface{}) {
 	g.mutex.Lock()
@@ -494,3 +494,50 @@ func TestGobTypeChange(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGobGetGuildValues(t *testing.T) {
+	bytesOut := bytes.NewBuffer([]byte{})
+	writer := TruncatableBuffer{bytesOut}
+	storage := GobStorage{
+		TempStorage: GetTempStorage(),
+		mutex:       &sync.Mutex{},
+	}
+	storage.SetWriter(writer)
+
+	guild := service.Guild{ServiceID: "0", GuildID: "0"}
+	storage.SetDefaultGuildValue("k0", "v0")
+	storage.SetGuildValue(guild, "k1", "v1")
+
+	values := storage.GetGuildValues(guild)
+	if len(values) != 2 || values["k0"] != "v0" || values["k1"] != "v1" {
+		t.Fail()
+	}
+}
+
+func TestGobUnsetGuildValue(t *testing.T) {
+	bytesOut := bytes.NewBuffer([]byte{})
+	writer := TruncatableBuffer{bytesOut}
+	storage := GobStorage{
+		TempStorage: GetTempStorage(),
+		mutex:       &sync.Mutex{},
+	}
+	storage.SetWriter(writer)
+
+	guild := service.Guild{ServiceID: "0", GuildID: "0"}
+	storage.SetGuildValue(guild, "k0", "v0")
+	storage.SetGuildValue(guild, "k1", "v1")
+	storage.UnsetGuildValue(guild, "k0")
+
+	storage, err := LoadFromBuffer(writer)
+	if err != nil {
+		t.Fail()
+	}
+
+	if _, ok := storage.GetGuildValue(guild, "k0"); ok {
+		t.Errorf("A value that was unset shouldn't be saved")
+	}
+
+	if value, ok := storage.GetGuildValue(guild, "k1"); !ok || value != "v1" {
+		t.Errorf("Other values should be saved")
+	}
+}
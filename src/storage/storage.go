@@ -8,7 +8,9 @@ import "github.com/BKrajancic/boby/m/v2/src/service"
 type Storage interface {
 	GetGuildValue(guild service.Guild, key string) (interface{}, bool)
 	SetGuildValue(guild service.Guild, key string, value interface{})
+	UnsetGuildValue(guild service.Guild, key string)
 	SetDefaultGuildValue(key string, value interface{})
+	GetGuildValues(guild service.Guild) map[string]interface{}
 
 	GetUserValue(user service.User, key string) (interface{}, bool)
 	SetUserValue(user service.User, key string, value interface{})
@@ -50,6 +50,24 @@ func (t *TempStorage) GetGuildValue(guild service.Guild, key string) (interface{
 	return val, ok
 }
 
+// GetGuildValues retrieves every value set for a Guild, including defaults that haven't
+// been overridden.
+func (t *TempStorage) GetGuildValues(guild service.Guild) map[string]interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	values := make(map[string]interface{})
+	for key, val := range t.DefaultGuildValues {
+		values[key] = val
+	}
+
+	for key, val := range t.GuildValues[guild.ServiceID][guild.GuildID] {
+		values[key] = val
+	}
+
+	return values
+}
+
 // SetGuildValue sets the value for key, for a Guild.
 func (t *TempStorage) SetGuildValue(guild service.Guild, key string, val interface{}) {
 	t.mutex.Lock()
@@ -70,6 +88,15 @@ func (t *TempStorage) SetGuildValue(guild service.Guild, key string, val interfa
 	t.GuildValues[guild.ServiceID][guild.GuildID][key] = val
 }
 
+// UnsetGuildValue removes the value for key, for a Guild. Afterwards, the default value
+// (if there is one) is retrieved for key.
+func (t *TempStorage) UnsetGuildValue(guild service.Guild, key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.GuildValues[guild.ServiceID][guild.GuildID], key)
+}
+
 // SetDefaultGuildValue sets the default value for key, for all Guilds.
 func (t *TempStorage) SetDefaultGuildValue(key string, val interface{}) {
 	t.mutex.Lock()
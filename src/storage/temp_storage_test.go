@@ -204,3 +204,41 @@ func TestSetAdminDisaster(t *testing.T) {
 
 	storage.SetAdmin(guild, "Test")
 }
+
+func TestGetGuildValues(t *testing.T) {
+	storage := TempStorage{mutex: &sync.Mutex{}}
+	guild := service.Guild{ServiceID: "0", GuildID: "0"}
+	storage.SetDefaultGuildValue("k0", "default")
+	storage.SetDefaultGuildValue("k1", "default")
+	storage.SetGuildValue(guild, "k1", "v1")
+	storage.SetGuildValue(service.Guild{ServiceID: "0", GuildID: "1"}, "k2", "v2")
+
+	values := storage.GetGuildValues(guild)
+	if len(values) != 2 {
+		t.Errorf("Only values for the guild and defaults should be returned")
+	}
+
+	if values["k0"] != "default" || values["k1"] != "v1" {
+		t.Errorf("Guild values should override defaults")
+	}
+}
+
+func TestUnsetGuildValue(t *testing.T) {
+	storage := TempStorage{mutex: &sync.Mutex{}}
+	guild := service.Guild{ServiceID: "0", GuildID: "0"}
+	storage.UnsetGuildValue(guild, "k0")
+
+	storage.SetDefaultGuildValue("k0", "default")
+	storage.SetGuildValue(guild, "k0", "v0")
+	storage.SetGuildValue(guild, "k1", "v1")
+	storage.UnsetGuildValue(guild, "k0")
+	storage.UnsetGuildValue(guild, "k1")
+
+	if value, ok := storage.GetGuildValue(guild, "k0"); !ok || value != "default" {
+		t.Errorf("The default should be retrieved once a value is unset")
+	}
+
+	if _, ok := storage.GetGuildValue(guild, "k1"); ok {
+		t.Errorf("A value that was unset shouldn't be retrieved")
+	}
+}
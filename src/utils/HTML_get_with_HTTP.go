@@ -2,14 +2,11 @@
 // This is useful because utils should only contain untestable functions.
 package utils
 
-import (
-	"io"
-	"net/http"
-)
+import "io"
 
-// HTMLGetWithHTTP retrieves a HTML page from a URL.
+// HTMLGetWithHTTP retrieves a HTML page from a URL, using a plain GET request.
 func HTMLGetWithHTTP(url string) (redirect string, out io.ReadCloser, err error) {
-	resp, err := http.Get(url)
+	resp, err := Request{URL: url}.Do()
 	if err == nil {
 		out = resp.Body
 		redirect = resp.Request.URL.String()
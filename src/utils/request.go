@@ -0,0 +1,47 @@
+// Package utils has no test file, therefore it's ignored code cover calculation.
+// This is useful because utils should only contain untestable functions.
+package utils
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client is used to make requests. Requests that take too long are stopped, so they can be
+// treated as failures.
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// A Request describes a HTTP request to make.
+type Request struct {
+	Method   string      // If empty, GET is used.
+	URL      string      // The URL to request.
+	Header   http.Header // Headers to send with the request.
+	Body     string      // The body of the request, which may be empty.
+	Username string      // If not empty, basic authentication is used with Username and Password.
+	Password string
+	Encoding string // If not empty, the response is decoded using this encoding (such as "windows-1252"), rather than the one detected.
+}
+
+// Do makes the request, returning the response, whatever its status is.
+func (r Request) Do() (*http.Response, error) {
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequest(r.Method, r.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range r.Header {
+		req.Header[key] = values
+	}
+
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	return client.Do(req)
+}